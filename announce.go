@@ -0,0 +1,144 @@
+package statsbot
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// StatChange is a value saved by NewStat, Old is nil if the user had no value before
+type StatChange struct {
+	Category Category
+	User     User
+	Old      *int
+	Value    int
+}
+
+// SetAnnounce opts the user in or out of milestone and overtake announcements
+func (s *Store) SetAnnounce(u *User, announce bool) error {
+	u.NoAnnounce = !announce
+	return s.db.Model(u).Update("no_announce", u.NoAnnounce).Error
+}
+
+// statSaved announces the change in the background so discord never holds up a write,
+// nothing is announced once the bot is draining
+func (b *Bot) statSaved(change StatChange) {
+	if b.config.AnnounceChannel == "" || change.User.NoAnnounce {
+		return
+	}
+
+	if !b.begin() {
+		return
+	}
+	go func() {
+		defer b.inflight.Done()
+		b.announce(change)
+	}()
+}
+
+// announce posts the milestones the change reached and who it overtook, at most once per AnnounceCooldownMinutes for each user
+func (b *Bot) announce(change StatChange) {
+	log := slog.With("category", change.Category.Name, "user", change.User.DiscordID)
+
+	lines, err := b.milestones(change)
+	if err != nil {
+		log.Error("Unable to check milestones", "error", err)
+		return
+	}
+	if len(lines) == 0 || !b.announceAllowed(change.User.DiscordID, time.Now()) {
+		return
+	}
+
+	_, err = b.session.ChannelMessageSendEmbed(b.config.AnnounceChannel, &discordgo.MessageEmbed{
+		Title:       "🎉 " + change.Category.FullName,
+		Description: strings.Join(lines, "\n"),
+		Color:       0xF1C40F,
+	})
+	if err != nil {
+		log.Warn("Unable to send announcement", "error", err)
+	}
+}
+
+// announceAllowed reports whether the user's cooldown is over, and starts a new one if it is
+func (b *Bot) announceAllowed(discordID string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.announced == nil {
+		b.announced = map[string]time.Time{}
+	}
+	cooldown := time.Duration(b.config.AnnounceCooldownMinutes) * time.Minute
+	if last, ok := b.announced[discordID]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	b.announced[discordID] = now
+	return true
+}
+
+// milestones describes the tiers and round numbers the change passed and the
+// best ranked user it overtook
+func (b *Bot) milestones(change StatChange) ([]string, error) {
+	c, name, v := change.Category, change.User.Name, change.Value
+	if change.Old != nil && !c.Better(v, *change.Old) {
+		return nil, nil
+	}
+
+	lines := []string{}
+	if t := c.Tier(v); t.Name != "" && (change.Old == nil || c.Tier(*change.Old) != t) {
+		lines = append(lines, fmt.Sprintf("%s reached %s!", name, t))
+	}
+	if change.Old != nil {
+		if round, ok := c.roundPassed(*change.Old, v); ok {
+			lines = append(lines, fmt.Sprintf("%s passed %s!", name, c.FormatValue(round)))
+		}
+	}
+
+	stats, err := b.store.GetAll(c)
+	if err != nil {
+		return nil, err
+	}
+	rank := 1
+	var overtaken *User
+	for _, stat := range stats {
+		if stat.UserID == change.User.ID {
+			continue
+		}
+		if c.Better(stat.Value, v) {
+			rank++
+			continue
+		}
+		if overtaken == nil && c.Better(v, stat.Value) && (change.Old == nil || !c.Better(*change.Old, stat.Value)) {
+			u := stat.User
+			overtaken = &u
+		}
+	}
+	if overtaken != nil {
+		if overtaken.NoAnnounce {
+			lines = append(lines, fmt.Sprintf("%s moved up to #%d in %s!", name, rank, c.FullName))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s just overtook %s for #%d in %s!", name, overtaken.Name, rank, c.FullName))
+		}
+	}
+	return lines, nil
+}
+
+// roundPassed returns the largest round number, 1, 2 or 5 times a power of ten
+// in displayed units, that the value went past going from old to v
+func (c Category) roundPassed(old, v int) (round int, ok bool) {
+	if c.LowerIsBetter || c.ValueType() == TypeDate || v <= old {
+		return 0, false
+	}
+
+	for step := c.scale() * 10; step <= int64(v); step *= 10 {
+		for _, m := range []int64{1, 2, 5} {
+			r := step * m
+			if int64(old) < r && r <= int64(v) {
+				round, ok = int(r), true
+			}
+		}
+	}
+	return round, ok
+}
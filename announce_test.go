@@ -0,0 +1,57 @@
+package statsbot
+
+import (
+	"reflect"
+	"testing"
+)
+
+// announcements sends content as the author and returns what was posted to the announce channel
+func announcements(b *Bot, f *FakeSession, authorID, content string) []string {
+	send(b, f, authorID, content)
+	b.inflight.Wait()
+
+	posted := []string{}
+	for _, msg := range f.Messages() {
+		if msg.ChannelID == b.config.AnnounceChannel && msg.Embed != nil {
+			posted = append(posted, msg.Embed.Description)
+		}
+	}
+	return posted
+}
+
+func TestAnnouncements(t *testing.T) {
+	b, f := newTestBot(t, &Config{AnnounceChannel: "announce"})
+
+	for _, tc := range []struct {
+		author  string
+		content string
+		want    []string
+	}{
+		{author: "1", content: "!stats add collector 25", want: []string{}},
+		{author: "2", content: "!stats add collector 40", want: []string{"bob reached 🥉 Bronze!\nbob just overtook alice for #1 in Collector!"}},
+		{author: "1", content: "!stats add collector 105", want: []string{"alice reached 🥉 Bronze!\nalice passed 100!\nalice just overtook bob for #1 in Collector!"}},
+		{author: "1", content: "!stats add collector 104", want: []string{}},
+		{author: "2", content: "!stats me announce off", want: []string{}},
+		{author: "1", content: "!stats add collector 200", want: []string{"alice passed 200!"}},
+		{author: "2", content: "!stats add collector 600", want: []string{}},
+		{author: "1", content: "!stats add collector 700", want: []string{"alice reached 🥈 Silver!\nalice passed 500!\nalice moved up to #1 in Collector!"}},
+	} {
+		if got := announcements(b, f, tc.author, tc.content); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: announced %q, want %q", tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestAnnouncementCooldown(t *testing.T) {
+	b, f := newTestBot(t, &Config{AnnounceChannel: "announce", AnnounceCooldownMinutes: 60})
+
+	if got := announcements(b, f, "1", "!stats add collector 40"); len(got) != 1 {
+		t.Fatalf("announced %q", got)
+	}
+	if got := announcements(b, f, "1", "!stats add collector 600"); len(got) != 0 {
+		t.Errorf("announced %q during the cooldown", got)
+	}
+	if got := announcements(b, f, "2", "!stats add collector 50"); len(got) != 1 {
+		t.Errorf("the cooldown held up bob's announcement: %q", got)
+	}
+}
@@ -0,0 +1,302 @@
+package statsbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	apiDefaultLimit = 50
+	apiMaxLimit     = 500
+)
+
+type apiCategory struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Min      int    `json:"min"`
+	Max      int    `json:"max"`
+	Order    int    `json:"order"`
+	Image    string `json:"image"`
+	Type     string `json:"type"`
+	Decimals int    `json:"decimals"`
+	Lower    bool   `json:"lower_is_better"`
+	Tiers    []Tier `json:"tiers"`
+}
+
+type apiUser struct {
+	Name      string        `json:"name"`
+	DiscordID string        `json:"discord_id"`
+	Active    bool          `json:"active"`
+	Team      string        `json:"team"`
+	Stats     []apiUserStat `json:"stats"`
+}
+
+type apiUserStat struct {
+	Category  string     `json:"category"`
+	FullName  string     `json:"full_name"`
+	Value     *float64   `json:"value"`
+	Display   string     `json:"display"`
+	Tier      string     `json:"tier"`
+	Rank      int        `json:"rank"`
+	Total     int        `json:"total"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+type apiHistory struct {
+	Category   string    `json:"category"`
+	Value      float64   `json:"value"`
+	Display    string    `json:"display"`
+	Correction bool      `json:"correction"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type apiPage struct {
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+	Items  interface{} `json:"items"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func (b *Bot) newAPICategory(c Category) apiCategory {
+	return apiCategory{
+		Name:     c.Name,
+		FullName: c.FullName,
+		Min:      c.Min,
+		Max:      c.Max,
+		Order:    c.Order,
+		Image:    b.medalIcon(c),
+		Type:     c.ValueType(),
+		Decimals: c.Decimals,
+		Lower:    c.LowerIsBetter,
+		Tiers:    c.Tiers(),
+	}
+}
+
+// APIHandler returns the read-only HTTP API for the bot's stats
+func (b *Bot) APIHandler() http.Handler {
+	mux := http.NewServeMux()
+	b.registerAPI(mux)
+	return mux
+}
+
+// HTTPHandler returns the API and the web dashboard together
+func (b *Bot) HTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	b.registerAPI(mux)
+	b.registerDashboard(mux)
+	return mux
+}
+
+func (b *Bot) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /categories", b.apiCategories)
+	mux.HandleFunc("GET /categories/{name}/leaderboard", b.apiLeaderboard)
+	mux.HandleFunc("GET /users/{name}", b.apiUser)
+	mux.HandleFunc("GET /users/{name}/history", b.apiUserHistory)
+}
+
+// serveHTTP runs the handler on addr until ctx is done
+func (b *Bot) serveHTTP(ctx context.Context, addr string, handler http.Handler) {
+	srv := &http.Server{Addr: addr, Handler: handler}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), b.shutdownTimeout())
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("HTTP server listening", "addr", addr)
+	err := srv.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		slog.Error("HTTP server stopped", "addr", addr, "error", err)
+	}
+}
+
+func (b *Bot) apiCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := b.store.GetCategories()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	items := []apiCategory{}
+	for _, c := range categories {
+		items = append(items, b.newAPICategory(c))
+	}
+	writeAPI(w, r, items)
+}
+
+func (b *Bot) apiLeaderboard(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := apiPagination(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := b.store.GetCategory(r.PathValue("name"))
+	if err == gorm.ErrRecordNotFound {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	rows, err := b.store.ExportRows(category.Name)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if q := r.URL.Query().Get("team"); q != "" {
+		team, ok := parseTeam(q)
+		if !ok {
+			writeAPIError(w, http.StatusBadRequest, ERR_INVALID_TEAM)
+			return
+		}
+		rows = filterTeamRows(rows, team)
+	}
+
+	page := apiPage{Total: len(rows), Limit: limit, Offset: offset}
+	if offset < len(rows) {
+		rows = rows[offset:]
+	} else {
+		rows = []ExportRow{}
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	page.Items = rows
+
+	writeAPI(w, r, page)
+}
+
+func (b *Bot) apiUser(w http.ResponseWriter, r *http.Request) {
+	user, err := b.store.GetUser(r.PathValue("name"))
+	if err == gorm.ErrRecordNotFound {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ranks, err := b.store.GetRanks(user)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := apiUser{Name: user.Name, DiscordID: user.DiscordID, Active: user.Active, Team: user.Team, Stats: []apiUserStat{}}
+	for _, r := range ranks {
+		us := apiUserStat{Category: r.Category.Name, FullName: r.Category.FullName, Rank: r.Rank, Total: r.Total}
+		if r.Stat != nil {
+			value := r.Category.Number(r.Stat.Value)
+			us.Value, us.UpdatedAt = &value, &r.Stat.UpdatedAt
+			us.Display = r.Category.FormatValue(r.Stat.Value)
+			us.Tier = r.Category.Tier(r.Stat.Value).Name
+		}
+		resp.Stats = append(resp.Stats, us)
+	}
+
+	writeAPI(w, r, resp)
+}
+
+func (b *Bot) apiUserHistory(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := apiPagination(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := b.store.GetUser(r.PathValue("name"))
+	if err == gorm.ErrRecordNotFound {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	history, total, err := b.store.GetHistory(user, r.URL.Query().Get("category"), limit, offset)
+	if err == gorm.ErrRecordNotFound {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	items := []apiHistory{}
+	for _, h := range history {
+		items = append(items, apiHistory{Category: h.Category.Name, Value: h.Category.Number(h.Value), Display: h.Category.FormatValue(h.Value), Correction: h.Correction, CreatedAt: h.CreatedAt})
+	}
+	writeAPI(w, r, apiPage{Total: total, Limit: limit, Offset: offset, Items: items})
+}
+
+// apiPagination reads the limit and offset query parameters
+func apiPagination(w http.ResponseWriter, r *http.Request) (limit int, offset int, ok bool) {
+	limit, offset = apiDefaultLimit, 0
+
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeAPIError(w, http.StatusBadRequest, ERR_INVALID_VALUE)
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if limit > apiMaxLimit {
+		limit = apiMaxLimit
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, ERR_INVALID_VALUE)
+			return 0, 0, false
+		}
+		offset = n
+	}
+
+	return limit, offset, true
+}
+
+// writeAPI writes v as JSON with an ETag, ServeContent answers 304 when the client already has it
+func writeAPI(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sum := sha1.Sum(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// writeAPIError writes err as JSON, server errors are logged and only their status is sent to the client
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		slog.Error("API request failed", "error", err)
+		err = errors.New(http.StatusText(status))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Error: err.Error()})
+}
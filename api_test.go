@@ -0,0 +1,81 @@
+package statsbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func apiGet(b *Bot, path string, v interface{}) int {
+	rec := httptest.NewRecorder()
+	b.APIHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if v != nil {
+		json.Unmarshal(rec.Body.Bytes(), v)
+	}
+	return rec.Code
+}
+
+func TestAPILeaderboard(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add collector 120")
+	send(b, f, "2", "!stats add collector 300")
+	send(b, f, "2", "!stats add jogger 3")
+
+	var page struct {
+		Total int
+		Items []ExportRow
+	}
+	if code := apiGet(b, "/categories/collector/leaderboard?limit=1", &page); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if page.Total != 2 || len(page.Items) != 1 || page.Items[0].User != "bob" || page.Items[0].Category != "collector" {
+		t.Errorf("got %+v", page)
+	}
+
+	for _, path := range []string{"/categories/all/leaderboard", "/categories/nosuchcategory/leaderboard"} {
+		if code := apiGet(b, path, nil); code != http.StatusNotFound {
+			t.Errorf("%s got status %d, want 404", path, code)
+		}
+	}
+	if code := apiGet(b, "/categories/collector/leaderboard?limit=0", nil); code != http.StatusBadRequest {
+		t.Errorf("limit=0 got status %d, want 400", code)
+	}
+}
+
+func TestAPIValuesUseDisplayUnits(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add jogger 12.5")
+
+	var board struct{ Items []ExportRow }
+	apiGet(b, "/categories/jogger/leaderboard", &board)
+	var user apiUser
+	apiGet(b, "/users/alice", &user)
+	var history struct{ Items []apiHistory }
+	apiGet(b, "/users/alice/history", &history)
+
+	if len(board.Items) != 1 || board.Items[0].Value != 12.5 {
+		t.Errorf("leaderboard got %+v", board.Items)
+	}
+	for _, us := range user.Stats {
+		if us.Category == "jogger" && (us.Value == nil || *us.Value != 12.5) {
+			t.Errorf("user got %+v", us)
+		}
+	}
+	if len(history.Items) != 1 || history.Items[0].Value != 12.5 {
+		t.Errorf("history got %+v", history.Items)
+	}
+}
+
+func TestAPIErrorsHideServerErrors(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+	b.store.Close()
+
+	var resp apiError
+	if code := apiGet(b, "/categories", &resp); code != http.StatusInternalServerError {
+		t.Fatalf("got status %d", code)
+	}
+	if resp.Error != "Internal Server Error" {
+		t.Errorf("got error %q", resp.Error)
+	}
+}
@@ -0,0 +1,171 @@
+package statsbot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// auditLimit is how many entries !stats audit shows
+const auditLimit = 25
+
+// AuditEntry records an admin action, or a stat set on behalf of another user
+type AuditEntry struct {
+	ID        int       `gorm:"primary_key"`
+	CreatedAt time.Time `gorm:"index"`
+	Action    string    `gorm:"size:20"`
+	ActorID   string    `gorm:"size:20;index"`
+	Actor     string    `gorm:"size:32"`
+	TargetID  string    `gorm:"size:20;index"`
+	Target    string    `gorm:"size:32"`
+	Category  string    `gorm:"size:25;index"`
+	OldValue  string    `gorm:"size:64"`
+	NewValue  string    `gorm:"size:64"`
+	MessageID string    `gorm:"size:20"`
+}
+
+func (AuditEntry) TableName() string {
+	return "AuditLog"
+}
+
+// String formats the entry as a single line
+func (e AuditEntry) String() string {
+	line := fmt.Sprintf("%s %s %s", e.CreatedAt.Format("2006-01-02 15:04"), e.Actor, e.Action)
+	if e.Category != "" {
+		line += " " + e.Category
+	}
+	if e.Target != "" {
+		line += " for " + e.Target
+	}
+	if e.OldValue != "" || e.NewValue != "" {
+		from, to := e.OldValue, e.NewValue
+		if from == "" {
+			from = "-"
+		}
+		if to == "" {
+			to = "-"
+		}
+		line += fmt.Sprintf(": %s → %s", from, to)
+	}
+	return line
+}
+
+// AuditFilter selects audit entries, the zero value matches everything
+type AuditFilter struct {
+	// UserID matches entries where the user is the actor or the target
+	UserID   string
+	Category string
+	Since    time.Time
+	Limit    int
+}
+
+// AddAudit saves an audit entry
+func (s *Store) AddAudit(e *AuditEntry) error {
+	return s.db.Create(e).Error
+}
+
+// GetAudit returns the entries matching f newest first
+func (s *Store) GetAudit(f AuditFilter) ([]AuditEntry, error) {
+	var entries []AuditEntry
+
+	q := s.db.Model(&AuditEntry{})
+	if f.UserID != "" {
+		q = q.Where("actor_id = ? OR target_id = ?", f.UserID, f.UserID)
+	}
+	if f.Category != "" {
+		q = q.Where("category = ?", f.Category)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+
+	res := q.Order("created_at desc, id desc").Find(&entries)
+	return entries, res.Error
+}
+
+// audit records the action taken by the author of m and mirrors it to the mod-log channel.
+// The action has already happened so failures are only logged.
+func (b *Bot) audit(ctx context.Context, m *discordgo.MessageCreate, e AuditEntry) {
+	log := loggerFrom(ctx)
+
+	e.ActorID, e.Actor, e.MessageID = m.Author.ID, m.Author.Username, m.ID
+	if u, err := b.store.GetUserByDiscordID(m.Author.ID); err == nil {
+		e.Actor = u.Name
+	}
+
+	err := b.store.AddAudit(&e)
+	if err != nil {
+		log.Error("Unable to save audit entry", "action", e.Action, "error", err)
+	}
+
+	if b.config.ModLogChannel != "" {
+		_, _ = b.session.ChannelMessageSend(b.config.ModLogChannel, e.String())
+	}
+}
+
+// PrintAudit lists the audit entries matching args, which can be a user, a
+// category and how far back to look as a date (2006-01-02) or a duration (24h, 7d, 2w)
+func (b *Bot) PrintAudit(ctx context.Context, s Session, m *discordgo.MessageCreate, args []string) (string, error) {
+	f := AuditFilter{Limit: auditLimit}
+
+	for _, arg := range args {
+		if arg == "" {
+			continue
+		}
+		if since, ok := parseSince(arg, time.Now()); ok {
+			f.Since = since
+		} else if c, err := b.store.GetCategory(strings.ToLower(arg)); err == nil {
+			f.Category = c.Name
+		} else if u, err := b.ResolveUser(ctx, s, m, arg); err == nil {
+			f.UserID = u.DiscordID
+		} else if err == ERR_USER_NOT_FOUND {
+			return "", fmt.Errorf("%s is not a user, category or date.", arg)
+		} else {
+			return "", err
+		}
+	}
+
+	entries, err := b.store.GetAudit(f)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "No audit entries found.", nil
+	}
+
+	message := ""
+	for _, e := range entries {
+		message += e.String() + "\n"
+	}
+	return TruncateMessage(message), nil
+}
+
+// parseSince reads a date or a duration back from now
+func parseSince(s string, now time.Time) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, true
+	}
+
+	if len(s) > 1 {
+		if n, err := strconv.Atoi(s[:len(s)-1]); err == nil && n > 0 {
+			switch s[len(s)-1] {
+			case 'd':
+				return now.AddDate(0, 0, -n), true
+			case 'w':
+				return now.AddDate(0, 0, -7*n), true
+			}
+		}
+	}
+
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(-d), true
+	}
+	return time.Time{}, false
+}
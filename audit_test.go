@@ -0,0 +1,56 @@
+package statsbot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"3d", now.AddDate(0, 0, -3), true},
+		{"2w", now.AddDate(0, 0, -14), true},
+		{"90m", now.Add(-90 * time.Minute), true},
+		{"0d", time.Time{}, false},
+		{"-1h", time.Time{}, false},
+		{"d", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+	} {
+		got, ok := parseSince(tc.in, now)
+		if ok != tc.ok || !got.Equal(tc.want) {
+			t.Errorf("parseSince(%q) = %v, %v, want %v, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestRegisterAudit(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	f.AddMember("g", &discordgo.User{ID: "1", Username: "alice"}, "")
+	f.AddMember("g", &discordgo.User{ID: "3", Username: "carol"}, "")
+	f.AddChannel("g", "c")
+
+	send(b, f, testAdminID, "!stats user carol")
+	send(b, f, testAdminID, "!stats user alice Ally")
+
+	entries, err := b.store.GetAudit(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ target, old, new string }{{"Ally", "alice", "Ally"}, {"carol", "", ""}}
+	if len(entries) != len(want) {
+		t.Fatalf("got audit entries %+v", entries)
+	}
+	for i, w := range want {
+		if e := entries[i]; e.Action != "register" || e.Target != w.target || e.OldValue != w.old || e.NewValue != w.new {
+			t.Errorf("got %+v, want %+v", e, w)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package statsbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// backupVersion is the format Export writes, backups without a version were written before it was recorded.
+// Version 2 added history, the audit log, pending submissions, events and category images.
+const backupVersion = 2
+
+// Backup is a full copy of the stats database
+type Backup struct {
+	Version    int             `json:"version"`
+	Categories []Category      `json:"categories"`
+	Users      []User          `json:"users"`
+	Stats      []BackupStat    `json:"stats"`
+	History    []BackupHistory `json:"history"`
+	Audit      []AuditEntry    `json:"audit"`
+	Pending    []BackupPending `json:"pending"`
+	Events     []BackupEvent   `json:"events"`
+	Images     []BackupImage   `json:"images"`
+}
+
+// BackupStat is a stat keyed by category name and discord ID so it can be restored into another database
+type BackupStat struct {
+	Category      string    `json:"category"`
+	DiscordID     string    `json:"discord_id"`
+	Value         int       `json:"value"`
+	OptionalValue string    `json:"optional_value,omitempty"`
+	Verified      bool      `json:"verified"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// BackupHistory is a StatHistory entry keyed like BackupStat
+type BackupHistory struct {
+	Category    string    `json:"category"`
+	DiscordID   string    `json:"discord_id"`
+	Value       int       `json:"value"`
+	Correction  bool      `json:"correction,omitempty"`
+	SubmittedBy string    `json:"submitted_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BackupPending is a submission waiting for review keyed like BackupStat
+type BackupPending struct {
+	Category   string    `json:"category"`
+	DiscordID  string    `json:"discord_id"`
+	Value      int       `json:"value"`
+	Reason     string    `json:"reason"`
+	Correction bool      `json:"correction,omitempty"`
+	ChannelID  string    `json:"channel_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BackupEvent is an event with its starting values, keyed by category name and discord ID
+type BackupEvent struct {
+	Name      string           `json:"name"`
+	Category  string           `json:"category"`
+	StartsAt  time.Time        `json:"starts_at"`
+	EndsAt    time.Time        `json:"ends_at"`
+	ChannelID string           `json:"channel_id,omitempty"`
+	Started   bool             `json:"started"`
+	Finished  bool             `json:"finished"`
+	CreatedAt time.Time        `json:"created_at"`
+	Snapshots []BackupSnapshot `json:"snapshots,omitempty"`
+}
+
+// BackupSnapshot is a user's value when an event started
+type BackupSnapshot struct {
+	DiscordID string `json:"discord_id"`
+	Value     int    `json:"value"`
+}
+
+// BackupImage is a custom category image, Data is base64 in the JSON
+type BackupImage struct {
+	Category    string `json:"category"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// Export writes every category, user, stat, history entry, audit entry, pending
+// submission, event and category image to w as JSON
+func (s *Store) Export(w io.Writer) error {
+	backup := Backup{Version: backupVersion}
+
+	if err := s.db.Order("name asc").Find(&backup.Categories).Error; err != nil {
+		return err
+	}
+	if err := s.db.Order("name asc").Find(&backup.Users).Error; err != nil {
+		return err
+	}
+
+	// the other tables refer to categories and users by ID, the backup by name and discord ID
+	categoryNames, discordIDs := map[int]string{}, map[int]string{}
+	for _, c := range backup.Categories {
+		categoryNames[c.ID] = c.Name
+	}
+	for _, u := range backup.Users {
+		discordIDs[u.ID] = u.DiscordID
+	}
+
+	var stats []Stat
+	if err := s.db.Preload("Category").Preload("User").Find(&stats).Error; err != nil {
+		return err
+	}
+	for _, stat := range stats {
+		backup.Stats = append(backup.Stats, BackupStat{
+			Category:      stat.Category.Name,
+			DiscordID:     stat.User.DiscordID,
+			Value:         stat.Value,
+			OptionalValue: stat.OptionalValue,
+			Verified:      stat.Verified,
+			UpdatedAt:     stat.UpdatedAt,
+		})
+	}
+
+	var history []StatHistory
+	if err := s.db.Order("created_at asc, id asc").Find(&history).Error; err != nil {
+		return err
+	}
+	for _, h := range history {
+		backup.History = append(backup.History, BackupHistory{
+			Category:    categoryNames[h.CategoryID],
+			DiscordID:   discordIDs[h.UserID],
+			Value:       h.Value,
+			Correction:  h.Correction,
+			SubmittedBy: h.SubmittedBy,
+			CreatedAt:   h.CreatedAt,
+		})
+	}
+
+	if err := s.db.Order("created_at asc, id asc").Find(&backup.Audit).Error; err != nil {
+		return err
+	}
+
+	var pending []PendingStat
+	if err := s.db.Order("created_at asc, id asc").Find(&pending).Error; err != nil {
+		return err
+	}
+	for _, p := range pending {
+		backup.Pending = append(backup.Pending, BackupPending{
+			Category:   categoryNames[p.CategoryID],
+			DiscordID:  discordIDs[p.UserID],
+			Value:      p.Value,
+			Reason:     p.Reason,
+			Correction: p.Correction,
+			ChannelID:  p.ChannelID,
+			CreatedAt:  p.CreatedAt,
+		})
+	}
+
+	var events []Event
+	if err := s.db.Order("starts_at asc, id asc").Find(&events).Error; err != nil {
+		return err
+	}
+	for _, e := range events {
+		var snapshots []EventSnapshot
+		if err := s.db.Where("event_id = ?", e.ID).Order("id asc").Find(&snapshots).Error; err != nil {
+			return err
+		}
+		be := BackupEvent{
+			Name:      e.Name,
+			Category:  categoryNames[e.CategoryID],
+			StartsAt:  e.StartsAt,
+			EndsAt:    e.EndsAt,
+			ChannelID: e.ChannelID,
+			Started:   e.Started,
+			Finished:  e.Finished,
+			CreatedAt: e.CreatedAt,
+		}
+		for _, snap := range snapshots {
+			be.Snapshots = append(be.Snapshots, BackupSnapshot{DiscordID: discordIDs[snap.UserID], Value: snap.Value})
+		}
+		backup.Events = append(backup.Events, be)
+	}
+
+	var images []CategoryImage
+	if err := s.db.Find(&images).Error; err != nil {
+		return err
+	}
+	for _, img := range images {
+		backup.Images = append(backup.Images, BackupImage{Category: categoryNames[img.CategoryID], ContentType: img.ContentType, Data: img.Data})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(backup)
+}
+
+// Import reads a backup written by Export and merges it into the database.
+// Categories are matched by name, users by discord ID, and entries the database
+// already has are skipped so a backup can be imported again.
+func (s *Store) Import(r io.Reader) error {
+	var backup Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return err
+	}
+	if backup.Version > backupVersion {
+		return fmt.Errorf("The backup is version %d, this statsbot can only import up to version %d.", backup.Version, backupVersion)
+	}
+
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	txs := s.withDB(tx)
+
+	err := txs.importBackup(backup)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (s *Store) importBackup(backup Backup) error {
+	categories := map[string]Category{}
+	for _, c := range backup.Categories {
+		c.ID = 0
+		res := s.db.Where("name = ?", c.Name).Assign(s.columns(&c)).FirstOrCreate(&c)
+		if res.Error != nil {
+			return res.Error
+		}
+		categories[c.Name] = c
+	}
+
+	users := map[string]User{}
+	for _, u := range backup.Users {
+		u.ID = 0
+		res := s.db.Where("discord_id = ?", u.DiscordID).Assign(s.columns(&u)).FirstOrCreate(&u)
+		if res.Error != nil {
+			return res.Error
+		}
+		users[u.DiscordID] = u
+	}
+
+	for _, bs := range backup.Stats {
+		c, ok := categories[bs.Category]
+		if !ok {
+			return fmt.Errorf("Stat for unknown category %s", bs.Category)
+		}
+		u, ok := users[bs.DiscordID]
+		if !ok {
+			return fmt.Errorf("Stat for unknown user %s", bs.DiscordID)
+		}
+
+		stat := Stat{CategoryID: c.ID, UserID: u.ID}
+		res := s.db.Where(Stat{CategoryID: c.ID, UserID: u.ID}).Assign(map[string]interface{}{
+			"value":          bs.Value,
+			"optional_value": bs.OptionalValue,
+			"verified":       bs.Verified,
+		}).FirstOrCreate(&stat)
+		if res.Error != nil {
+			return res.Error
+		}
+		if !bs.UpdatedAt.IsZero() {
+			res = s.db.Model(&stat).UpdateColumn("updated_at", bs.UpdatedAt)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+	}
+
+	for _, bh := range backup.History {
+		c, u, err := backupKeys(categories, users, bh.Category, bh.DiscordID)
+		if err != nil {
+			return err
+		}
+		h := StatHistory{CategoryID: c.ID, UserID: u.ID, Value: bh.Value, Correction: bh.Correction, SubmittedBy: bh.SubmittedBy, CreatedAt: bh.CreatedAt}
+		err = s.createMissing(&h, "category_id = ? AND user_id = ? AND created_at = ?", c.ID, u.ID, bh.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range backup.Audit {
+		e.ID = 0
+		err := s.createMissing(&e, "created_at = ? AND action = ? AND actor_id = ? AND target_id = ?", e.CreatedAt, e.Action, e.ActorID, e.TargetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, bp := range backup.Pending {
+		c, u, err := backupKeys(categories, users, bp.Category, bp.DiscordID)
+		if err != nil {
+			return err
+		}
+		p := PendingStat{CategoryID: c.ID, UserID: u.ID, Value: bp.Value, Reason: bp.Reason, Correction: bp.Correction, ChannelID: bp.ChannelID, CreatedAt: bp.CreatedAt}
+		err = s.createMissing(&p, "category_id = ? AND user_id = ? AND created_at = ?", c.ID, u.ID, bp.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, be := range backup.Events {
+		c, ok := categories[be.Category]
+		if !ok {
+			return fmt.Errorf("Event for unknown category %s", be.Category)
+		}
+		e := Event{Name: be.Name, CategoryID: c.ID, StartsAt: be.StartsAt, EndsAt: be.EndsAt, ChannelID: be.ChannelID, Started: be.Started, Finished: be.Finished, CreatedAt: be.CreatedAt}
+		err := s.createMissing(&e, "name = ? AND category_id = ? AND starts_at = ?", e.Name, c.ID, e.StartsAt)
+		if err != nil {
+			return err
+		}
+		for _, snap := range be.Snapshots {
+			u, ok := users[snap.DiscordID]
+			if !ok {
+				return fmt.Errorf("Event snapshot for unknown user %s", snap.DiscordID)
+			}
+			err = s.createMissing(&EventSnapshot{EventID: e.ID, UserID: u.ID, Value: snap.Value}, "event_id = ? AND user_id = ?", e.ID, u.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, bi := range backup.Images {
+		c, ok := categories[bi.Category]
+		if !ok {
+			return fmt.Errorf("Image for unknown category %s", bi.Category)
+		}
+		res := s.db.Save(&CategoryImage{CategoryID: c.ID, ContentType: bi.ContentType, Data: bi.Data})
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+
+	return nil
+}
+
+// backupKeys finds the imported category and user a backup entry refers to
+func backupKeys(categories map[string]Category, users map[string]User, category, discordID string) (Category, User, error) {
+	c, ok := categories[category]
+	if !ok {
+		return c, User{}, fmt.Errorf("Entry for unknown category %s", category)
+	}
+	u, ok := users[discordID]
+	if !ok {
+		return c, u, fmt.Errorf("Entry for unknown user %s", discordID)
+	}
+	return c, u, nil
+}
+
+// createMissing loads the record matching the query into record, or creates record if there is none
+func (s *Store) createMissing(record interface{}, query string, args ...interface{}) error {
+	res := s.db.Where(query, args...).First(record)
+	if res.Error == gorm.ErrRecordNotFound {
+		return s.db.Set("gorm:save_associations", false).Create(record).Error
+	}
+	return res.Error
+}
+
+// columns returns every column of the record but its primary key, including the
+// zero ones, so restoring a backup overwrites seeded values and keeps up with new fields
+func (s *Store) columns(record interface{}) map[string]interface{} {
+	columns := map[string]interface{}{}
+	for _, f := range s.db.NewScope(record).Fields() {
+		if f.IsPrimaryKey || f.IsIgnored || !f.IsNormal {
+			continue
+		}
+		columns[f.DBName] = f.Field.Interface()
+	}
+	return columns
+}
@@ -0,0 +1,123 @@
+package statsbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	from := newTestStore(t)
+
+	jogger, _ := from.GetCategory("jogger")
+	if err := from.SetValueType(&jogger, TypeInteger, 0); err != nil {
+		t.Fatal(err)
+	}
+	jogger.LowerIsBetter, jogger.Monotonic, jogger.Bronze, jogger.Silver, jogger.Gold, jogger.Platinum = true, true, 0, 0, 0, 0
+	if err := from.UpdateCategory(&jogger); err != nil {
+		t.Fatal(err)
+	}
+	alice := User{DiscordID: "1", Name: "alice", Team: "valor", CustomTeam: true, NoAnnounce: true}
+	if err := from.InsertUser(&alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := from.AddStat(jogger, alice, 1234); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := from.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	to := newTestStore(t)
+	if err := to.Import(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := to.GetCategory("jogger")
+	if c.ValueType() != TypeInteger || c.Decimals != 0 || !c.LowerIsBetter || !c.Monotonic || len(c.Tiers()) != 0 {
+		t.Errorf("jogger was restored as %+v", c)
+	}
+	u, _ := to.GetUserByDiscordID("1")
+	if u.Team != "valor" || !u.CustomTeam || !u.NoAnnounce {
+		t.Errorf("alice was restored as %+v", u)
+	}
+	stat, err := to.GetStat(c, u)
+	if err != nil || c.FormatValue(stat.Value) != "1,234" {
+		t.Errorf("alice's jogger value was restored as %v, %v", stat.Value, err)
+	}
+}
+
+func TestBackupKeepsHistoryAndRecords(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add collector 100")
+	send(b, f, "1", "!stats add collector 200")
+	send(b, f, testAdminID, "!stats add collector bob 50")
+
+	from := b.store
+	c, _ := from.GetCategory("collector")
+	bob, _ := from.GetUser("bob")
+	if err := from.AddPending(&PendingStat{CategoryID: c.ID, UserID: bob.ID, Value: 9000, Reason: "too fast"}); err != nil {
+		t.Fatal(err)
+	}
+	e := Event{Name: "Spring", CategoryID: c.ID, StartsAt: time.Now(), EndsAt: time.Now().Add(time.Hour)}
+	if err := from.AddEvent(&e); err != nil {
+		t.Fatal(err)
+	}
+	if err := from.StartEvent(&e); err != nil {
+		t.Fatal(err)
+	}
+	if err := from.SetCategoryImage(c, []byte("\x89PNG\r\n\x1a\nimage")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := from.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	to := newTestStore(t)
+	data := buf.Bytes()
+	for i := 0; i < 2; i++ {
+		if err := to.Import(bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alice, _ := to.GetUser("alice")
+	if _, total, _ := to.GetHistory(alice, "", 10, 0); total != 2 {
+		t.Errorf("alice has %d history entries after importing twice, want 2", total)
+	}
+	if undone, restored, err := to.UndoLast(alice); err != nil || undone.Value != 200 || restored == nil || restored.Value != 100 {
+		t.Errorf("undo after a restore got %+v, %+v, %v", undone, restored, err)
+	}
+	if entries, _ := to.GetAudit(AuditFilter{}); len(entries) != 1 || entries[0].Target != "bob" {
+		t.Errorf("got audit entries %+v", entries)
+	}
+	if pending, _ := to.GetPendingStats(); len(pending) != 1 || pending[0].Value != 9000 || pending[0].User.Name != "bob" {
+		t.Errorf("got pending %+v", pending)
+	}
+	events, _ := to.GetOpenEvents()
+	if len(events) != 1 || !events[0].Started {
+		t.Fatalf("got events %+v", events)
+	}
+	var snapshots int
+	to.db.Model(&EventSnapshot{}).Where("event_id = ?", events[0].ID).Count(&snapshots)
+	if snapshots != 2 {
+		t.Errorf("got %d snapshots, want 2", snapshots)
+	}
+	tc, _ := to.GetCategory("collector")
+	if contentType, _, err := to.MedalImage(tc); err != nil || contentType != "image/png" {
+		t.Errorf("got image %s, %v", contentType, err)
+	}
+}
+
+func TestImportRejectsNewerBackups(t *testing.T) {
+	s := newTestStore(t)
+
+	data, _ := json.Marshal(Backup{Version: backupVersion + 1})
+	if err := s.Import(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Errorf("got %v", err)
+	}
+}
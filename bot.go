@@ -1,24 +1,55 @@
 package statsbot
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-// BotID for discord
 var (
-	BotID string
-	goBot *discordgo.Session
-
 	ERR_COMMAND_UNRECOGNIZED = errors.New("Command not recognized")
 )
 
+// defaultShutdownTimeout is how long Run waits for in-flight commands when the config doesn't say
+const defaultShutdownTimeout = 10 * time.Second
+
+// Bot is a single statsbot instance bound to a discord session and a stats database
+type Bot struct {
+	ID       string
+	config   *Config
+	store    *Store
+	session  Session
+	gateway  *discordgo.Session
+	commands map[string]BotCommand
+	metrics  *botMetrics
+
+	// ctx is handed to command handlers and is cancelled if draining takes longer than the shutdown timeout
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
+	closed   sync.Once
+
+	// forget holds when each pending !stats me forget confirmation expires, by discord ID
+	forget map[string]time.Time
+
+	// announced holds when each user was last announced, by discord ID
+	announced map[string]time.Time
+}
+
 type botResponse struct {
-	s       *discordgo.Session
+	bot     *Bot
+	s       Session
 	m       *discordgo.MessageCreate
 	command string
 	fields  []string
@@ -39,9 +70,6 @@ type BotCommand struct {
 	Do
 }
 
-var cmdMap map[string]BotCommand
-var cmdList map[string]BotCommand
-
 // PrintInfo prints the info for a discord command
 func (cmd *BotCommand) PrintInfo(prefix string) string {
 	examples := Example(strings.Replace(cmd.Format, "!", prefix, 1))
@@ -51,9 +79,9 @@ func (cmd *BotCommand) PrintInfo(prefix string) string {
 	return fmt.Sprintln(cmd.Info, examples)
 }
 
-//NewBotResponse creates an instance of a bot interaction
-func NewBotResponse(s *discordgo.Session, m *discordgo.MessageCreate, fields []string) *botResponse {
-	return &botResponse{s: s, m: m, fields: fields}
+// NewBotResponse creates an instance of a bot interaction
+func (b *Bot) NewBotResponse(m *discordgo.MessageCreate, fields []string) *botResponse {
+	return &botResponse{bot: b, s: b.session, m: m, fields: fields}
 }
 
 // GetCommand gets the BotCommand for the input
@@ -64,7 +92,7 @@ func (b *botResponse) GetCommand(prefix string) (cmd *BotCommand) {
 	}
 
 	name := strings.ToLower(strings.Replace(b.fields[1], prefix, "", 1))
-	if c, ok := cmdMap[name]; ok {
+	if c, ok := b.bot.commands[name]; ok {
 		return &c
 	} else {
 		b.err = ERR_COMMAND_UNRECOGNIZED
@@ -72,68 +100,211 @@ func (b *botResponse) GetCommand(prefix string) (cmd *BotCommand) {
 	}
 }
 
-// Start starts the bot
-func Start() {
-	var err error
-	goBot, err = discordgo.New("Bot " + config.Token)
-	if err != nil {
-		fmt.Println(err.Error())
-		return
+// New creates a bot from the config, stats database and discord session
+func New(cfg *Config, store *Store, session *discordgo.Session) (*Bot, error) {
+	if session == nil {
+		return nil, errors.New("statsbot: session is required")
 	}
 
-	u, err := goBot.User("@me")
+	b, err := NewWithSession(cfg, store, discordSession{session})
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return nil, err
+	}
+	b.gateway = session
+
+	return b, nil
+}
+
+// NewWithSession creates a bot that sends its replies through session.
+// The bot can handle messages passed to HandleMessage but can't Run.
+func NewWithSession(cfg *Config, store *Store, session Session) (*Bot, error) {
+	if cfg == nil {
+		return nil, errors.New("statsbot: config is required")
+	}
+	if store == nil {
+		return nil, errors.New("statsbot: store is required")
+	}
+	if session == nil {
+		return nil, errors.New("statsbot: session is required")
 	}
 
-	BotID = u.ID
+	ctx, cancel := context.WithCancel(context.Background())
+	metrics := newBotMetrics(store)
+
+	// the bot's settings go on its own copy of the store, so bots sharing one don't overwrite each other's
+	store = store.withDB(store.db)
+	store.leaveGrace = time.Duration(cfg.LeaveGraceDays) * 24 * time.Hour
+
+	b := &Bot{
+		config:   cfg,
+		store:    store,
+		session:  instrumentedSession{session, metrics},
+		commands: map[string]BotCommand{},
+		metrics:  metrics,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	store.onSave = b.statSaved
+	return b, nil
+}
+
+// Run connects the bot to discord and handles messages until ctx is done.
+// Once ctx is done no new commands are accepted and Run waits for the
+// in-flight ones to finish before returning.
+//
+// The bot needs the privileged Server Members and Message Content intents,
+// both have to be enabled for it in the discord developer portal.
+func (b *Bot) Run(ctx context.Context) error {
+	if b.gateway == nil {
+		return errors.New("statsbot: bot has no discord gateway to run on")
+	}
 
-	err = InitDB()
+	u, err := b.gateway.User("@me")
 	if err != nil {
-		log.Fatalf("Unable to start stats database: %+v\n", err.Error())
+		return err
 	}
 
-	goBot.AddHandler(messageHandler)
-	err = goBot.Open()
+	b.ID = u.ID
+
+	for _, handler := range []interface{}{b.messageHandler, b.memberAddHandler, b.memberUpdateHandler, b.memberRemoveHandler, b.interactionHandler} {
+		remove := b.gateway.AddHandler(handler)
+		defer remove()
+	}
+
+	var connects int32
+	removeConnect := b.gateway.AddHandler(func(s *discordgo.Session, c *discordgo.Connect) {
+		if atomic.AddInt32(&connects, 1) > 1 {
+			b.metrics.reconnects.Inc()
+		}
+	})
+	defer removeConnect()
+
+	// member events drive name, team and leave tracking, and commands arrive without content otherwise
+	b.gateway.Identify.Intents |= discordgo.IntentsGuildMembers | discordgo.IntentMessageContent
+
+	err = b.gateway.Open()
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return err
 	}
 
-	err = goBot.UpdateStatus(0, "!stats")
+	err = b.gateway.UpdateGameStatus(0, b.config.BotPrefix+"stats")
 	if err != nil {
-		fmt.Println("Unable to update status: ", err.Error())
+		slog.Warn("Unable to update status", "error", err)
 	}
 
-	log.Println("Bot is running!")
+	slog.Info("Bot is running", "user", b.ID)
+
+	if b.config.HTTPAddr != "" {
+		go b.serveHTTP(ctx, b.config.HTTPAddr, b.HTTPHandler())
+	}
+	if b.config.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", b.MetricsHandler())
+		go b.serveHTTP(ctx, b.config.MetricsAddr, mux)
+	}
+	go b.runEvents(ctx)
+
+	<-ctx.Done()
+	slog.Info("Bot is shutting down")
+
+	return b.drain()
+}
+
+// drain stops accepting commands and waits for the running ones to finish
+func (b *Bot) drain() error {
+	b.mu.Lock()
+	b.draining = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(b.shutdownTimeout()):
+		b.cancel()
+		<-done
+		return errors.New("statsbot: timed out waiting for commands to finish")
+	}
 }
 
-func messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+// shutdownTimeout is how long Run waits for in-flight commands once its context is done
+func (b *Bot) shutdownTimeout() time.Duration {
+	if b.config.ShutdownTimeoutSeconds > 0 {
+		return time.Duration(b.config.ShutdownTimeoutSeconds) * time.Second
+	}
+	return defaultShutdownTimeout
+}
 
-	if !strings.HasPrefix(m.Content, BotPrefix+"stats") {
+// begin registers an in-flight command, it returns false once the bot is draining
+func (b *Bot) begin() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.draining {
+		return false
+	}
+	b.inflight.Add(1)
+	return true
+}
+
+// Close disconnects the bot from discord. The store belongs to whoever passed
+// it to New and may be shared with other bots, so it is left open for them to close.
+// It is safe to call more than once.
+func (b *Bot) Close() error {
+	var err error
+	b.closed.Do(func() {
+		b.cancel()
+		if b.gateway != nil {
+			err = b.gateway.Close()
+		}
+	})
+	return err
+}
+
+func (b *Bot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+	b.HandleMessage(m)
+}
+
+// HandleMessage runs the stats commands in m and replies through the bot's session
+func (b *Bot) HandleMessage(m *discordgo.MessageCreate) {
+	if !strings.HasPrefix(m.Content, b.config.BotPrefix+"stats") {
+		return
+	}
+
+	if m.Author.ID == b.ID {
 		return
 	}
 
-	if m.Author.ID == BotID {
+	if !b.begin() {
 		return
 	}
+	defer b.inflight.Done()
 
+	log := messageLogger(m)
+	ctx := withLogger(b.ctx, log)
 	lines := strings.Split(m.Content, "\n")
 
 	for _, line := range lines {
 
-		msg := strings.TrimSpace(strings.Replace(line, BotPrefix+"stats ", "", -1))
-		fmt.Println(msg)
+		msg := strings.TrimSpace(strings.Replace(line, b.config.BotPrefix+"stats ", "", -1))
 		if len(msg) == 0 {
 			continue
 		}
-		handleLine(s, m, msg)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Debug("Handling command", "content", msg)
+		b.handleLine(ctx, b.session, m, msg)
 	}
 	return
 }
 
-func handleLine(s *discordgo.Session, m *discordgo.MessageCreate, message string) {
+func (b *Bot) handleLine(ctx context.Context, s Session, m *discordgo.MessageCreate, message string) {
 	//bot := NewBotResponse(s, m, strings.Fields(m.Content))
 
 	fields := strings.Split(message, " ")
@@ -141,111 +312,143 @@ func handleLine(s *discordgo.Session, m *discordgo.MessageCreate, message string
 		return
 	}
 
+	command, outcome, reason := fields[0], "ok", ""
+	defer func(start time.Time) {
+		d := time.Since(start)
+		b.metrics.observeCommand(command, outcome, d)
+
+		log := loggerFrom(ctx).With("command", command, "outcome", outcome, "duration", d)
+		if outcome == "error" {
+			log.Warn("Command failed", "error", reason)
+		} else {
+			log.Info("Handled command")
+		}
+	}(time.Now())
+	// replies never ping, they can quote names and values users chose
+	respond := func(content string) {
+		_, _ = s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{Content: content, AllowedMentions: &discordgo.MessageAllowedMentions{}})
+	}
+	fail := func(msg string) {
+		outcome, reason = "error", msg
+		respond(msg)
+	}
+
 	switch fields[0] {
 	case "help":
 		helpmessage := "use `!stats user` to add your user to the stats program, if not already added."
 		helpmessage += "\nuse `!stats add {category} {value}` to add your stats."
+		helpmessage += "\nuse `!stats undo` to take back your last submission"
+		helpmessage += "\nuse `!stats fix {category} {value}` to correct a mistake without it counting as progress"
+		helpmessage += "\nuse `!stats me` to rename yourself, pause reminders or delete your data"
 		helpmessage += "\nuse `!stats categories` to see all available categories"
 		helpmessage += "\nuse `!stats users` to see all users"
-		helpmessage += "\nuse `!stats print {category}` to print rankings"
+		helpmessage += "\nuse `!stats print {category} [team]` to print rankings"
+		helpmessage += "\nuse `!stats team [mystic|valor|instinct|none]` to see or set your team, and `!stats teams {category}` to compare the teams"
+		helpmessage += "\nuse `!stats tiers {category}` to see the medal tiers and how far you are from the next one"
+		helpmessage += "\nuse `!stats event [id]` to see the events and their standings"
+		helpmessage += "\nuse `!stats export [category|all] [csv|json|xlsx]` to download the rankings as a file"
 
-		_, _ = s.ChannelMessageSend(m.ChannelID, helpmessage)
+		respond(helpmessage)
 	case "print":
 		c := strings.TrimSpace(strings.Replace(message, "print", "", 1))
 		if c == "" || c == "all" {
-			categories, err := GetCategories()
+			categories, err := b.store.GetCategories()
 			if err != nil {
-				log.Println(err.Error())
+				fail(err.Error())
 			}
 			for _, category := range categories {
-				stats, err := PrintStats(category.Name)
+				stats, err := b.PrintStats(ctx, category.Name)
 				if err != nil {
-					_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+					fail(err.Error())
 				} else {
 					emb := NewEmbed().
-						SetColor(0x0B9EFF).SetAuthor(category.FullName, category.Image).
-						SetDescription(stats).MessageEmbed
-					_, _ = s.ChannelMessageSendEmbed(m.ChannelID, emb)
+						SetColor(0x0B9EFF).
+						SetDescription(stats)
+					_, _ = b.sendCategoryEmbed(s, m.ChannelID, category, emb)
 					//_, _ = s.ChannelMessageSend(m.ChannelID, stats)
 				}
 			}
 		} else {
-			category, _ := GetCategory(c)
-			stats, err := PrintStats(c)
+			category, _ := b.store.GetCategory(strings.ToLower(strings.Split(c, " ")[0]))
+			stats, err := b.PrintStats(ctx, c)
 			if err != nil {
-				_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+				fail(err.Error())
 			} else {
 				emb := NewEmbed().
-					SetColor(0x0B9EFF).SetAuthor(category.FullName, category.Image).
-					SetDescription(stats).MessageEmbed
-				_, _ = s.ChannelMessageSendEmbed(m.ChannelID, emb)
+					SetColor(0x0B9EFF).
+					SetDescription(stats)
+				_, _ = b.sendCategoryEmbed(s, m.ChannelID, category, emb)
 			}
 			//_, _ = s.ChannelMessageSend(m.ChannelID, stats)
 		}
 	//Print all stats
 	case "add":
-		err := AddStat(m.Author, strings.Replace(message, "add ", "", 1))
+		err := b.AddStat(ctx, m, strings.Replace(message, "add ", "", 1))
+		var held *HeldForReviewError
+		if errors.As(err, &held) {
+			outcome = "review"
+			respond(err.Error())
+		} else if err != nil {
+			fail(err.Error())
+		} else {
+			respond("Successfully added stat!")
+		}
+	case "me":
+		reply, err := b.Me(m, fields[1:])
+		if err != nil {
+			fail(err.Error())
+		} else {
+			respond(reply)
+		}
+	case "undo":
+		reply, err := b.Undo(m)
 		if err != nil {
-			_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+			fail(err.Error())
 		} else {
-			_, _ = s.ChannelMessageSend(m.ChannelID, "Successfully added stat!")
+			respond(reply)
+		}
+	case "fix":
+		err := b.FixStat(ctx, m, fields[1:])
+		var held *HeldForReviewError
+		if errors.As(err, &held) {
+			outcome = "review"
+			respond(err.Error())
+		} else if err != nil {
+			fail(err.Error())
+		} else {
+			respond("Successfully corrected stat!")
+		}
+	case "remove":
+		if !b.store.CheckAdmin(m.Author.ID) {
+			outcome = "denied"
+			return
+		}
+		err := b.RemoveStat(ctx, m, fields[1:])
+		if err != nil {
+			fail(err.Error())
+		} else {
+			respond("Successfully removed stat!")
 		}
 	case "user":
-		message = strings.TrimSpace(strings.Replace(message, "user", "", 1))
-		fields := strings.Split(message, " ")
-		var user *discordgo.User
-		var name string
+		args := strings.Fields(message)[1:]
 		var err error
-
-		if len(fields) > 0 {
-			if !CheckAdmin(m.Author.ID) {
-				return
-			}
-			if strings.Contains(message, "<@") {
-				user = m.Mentions[0]
-			} else {
-				username := fields[0]
-
-				channel, err := s.Channel(m.ChannelID)
-				guild, err := s.Guild(channel.GuildID)
-				if err != nil {
-					break
-				}
-
-				for _, mem := range guild.Members {
-					if mem.Nick == username {
-						user = mem.User
-						name = username
-					} else if mem.User.Username == username {
-						user = mem.User
-					}
-				}
-			}
-
-			if len(fields) == 2 {
-				name = fields[1]
-			}
-
-			if user != nil {
-				AddUser(user, name)
-			} else {
-				err = errors.New("User not found.")
-			}
-
+		if len(args) == 0 {
+			err = b.AddUser(m.Author, "")
+		} else if !b.store.CheckAdmin(m.Author.ID) {
+			outcome = "denied"
+			return
 		} else {
-			fmt.Println("Adding...")
-			AddUser(m.Author, "")
-			//err = errors.New("Command unrecognized.")
+			err = b.RegisterMember(ctx, s, m, args)
 		}
 		if err != nil {
-			_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+			fail(err.Error())
 		} else {
-			_, _ = s.ChannelMessageSend(m.ChannelID, "Successfully added user!")
+			respond("Successfully added user!")
 		}
 	case "users":
-		users, err := PrintUsers()
+		users, err := b.store.PrintUsers()
 		if err != nil {
-			_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+			fail(err.Error())
 		} else {
 			emb := NewEmbed().
 				SetColor(0x0B9EFF).
@@ -254,9 +457,9 @@ func handleLine(s *discordgo.Session, m *discordgo.MessageCreate, message string
 			//_, _ = s.ChannelMessageSend(m.ChannelID, users)
 		}
 	case "categories":
-		categories, err := PrintCategories()
+		categories, err := b.store.PrintCategories()
 		if err != nil {
-			_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+			fail(err.Error())
 		} else {
 			emb := NewEmbed().
 				SetColor(0x0B9EFF).
@@ -264,38 +467,156 @@ func handleLine(s *discordgo.Session, m *discordgo.MessageCreate, message string
 			_, _ = s.ChannelMessageSendEmbed(m.ChannelID, emb)
 		}
 	case "rank", "ranks":
-		message = strings.TrimSpace(strings.Replace(strings.Replace(message, "ranks", "", 1), "rank", "", 1))
-		fields := strings.Split(message, " ")
-		userID := ""
-		fmt.Println(fields)
-		if len(fields) > 0 && fields[0] != "" {
-			u, err := GetUser(fields[0])
-			if err != nil {
-				_, _ = s.ChannelMessageSend(m.ChannelID, "Unable to find user")
-				return
-			}
-			userID = u.DiscordID
+		args := strings.Fields(message)[1:]
+		var user User
+		var err error
+		if len(args) > 0 {
+			user, err = b.ResolveUser(ctx, s, m, args[0])
 		} else {
-			userID = m.Author.ID
+			user, err = b.store.GetUserByDiscordID(m.Author.ID)
 		}
-		user, err := GetUser(userID)
 		if err != nil {
-			_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+			fail(err.Error())
+			return
 		}
-		ranks, err := PrintRanks(user)
+		ranks, err := b.PrintRanks(user)
 		if err != nil {
-			_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+			fail(err.Error())
 		} else {
-			_, _ = s.ChannelMessageSend(m.ChannelID, ranks)
+			respond(ranks)
 		}
 	case "remind":
-		if !CheckAdmin(m.Author.ID) {
+		if !b.store.CheckAdmin(m.Author.ID) {
+			outcome = "denied"
+			return
+		}
+		b.audit(ctx, m, AuditEntry{Action: "remind"})
+		err := b.SendReminders(ctx, m.ChannelID)
+		if err != nil {
+			fail(err.Error())
+		}
+	case "export":
+		category, format := "all", "csv"
+		for _, f := range fields[1:] {
+			if isExportFormat(f) {
+				format = f
+			} else if f != "" {
+				category = f
+			}
+		}
+		name, data, err := b.Export(strings.ToLower(category), format)
+		if err != nil {
+			fail(err.Error())
+		} else {
+			_, _ = s.ChannelFileSend(m.ChannelID, name, bytes.NewReader(data))
+		}
+	case "image":
+		if !b.store.CheckAdmin(m.Author.ID) {
+			outcome = "denied"
+			return
+		}
+		if len(fields) != 2 {
+			respond("Use `!stats image {category}` with an image attached.")
+			return
+		}
+		err := b.UploadCategoryImage(ctx, m, strings.ToLower(fields[1]))
+		if err != nil {
+			fail(err.Error())
+		} else {
+			b.audit(ctx, m, AuditEntry{Action: "image", Category: strings.ToLower(fields[1])})
+			respond("Successfully updated image!")
+		}
+	case "import":
+		if !b.store.CheckAdmin(m.Author.ID) {
+			outcome = "denied"
 			return
 		}
-		err := SendReminders(s, m.ChannelID)
+		dryRun := len(fields) > 1 && (fields[1] == "dry" || fields[1] == "dryrun" || fields[1] == "dry-run")
+		report, err := b.ImportAttachment(ctx, m, dryRun)
 		if err != nil {
-			_, _ = s.ChannelMessageSend(m.ChannelID, err.Error())
+			fail(err.Error())
+		} else {
+			if !report.DryRun {
+				b.audit(ctx, m, AuditEntry{Action: "import", NewValue: fmt.Sprintf("%d stats", report.Stats)})
+			}
+			respond(TruncateMessage(report.String()))
+		}
+	case "audit":
+		if !b.store.CheckAdmin(m.Author.ID) {
+			outcome = "denied"
+			return
+		}
+		entries, err := b.PrintAudit(ctx, s, m, fields[1:])
+		if err != nil {
+			fail(err.Error())
+		} else {
+			respond(entries)
+		}
+	case "team":
+		reply, err := b.Team(m, fields[1:])
+		if err != nil {
+			fail(err.Error())
+		} else {
+			respond(reply)
+		}
+	case "teams":
+		if len(fields) != 2 {
+			fail("Use `!stats teams {category}`.")
+			return
+		}
+		category, err := b.store.GetCategory(strings.ToLower(fields[1]))
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		teams, err := b.PrintTeams(category)
+		if err != nil {
+			fail(err.Error())
+		} else {
+			respond(teams)
+		}
+	case "tiers":
+		if len(fields) != 2 {
+			fail("Use `!stats tiers {category}`.")
+			return
+		}
+		category, err := b.store.GetCategory(strings.ToLower(fields[1]))
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		var author *User
+		if u, err := b.store.GetUserByDiscordID(m.Author.ID); err == nil {
+			author = &u
+		}
+		tiers, err := b.PrintTiers(category, author)
+		if err != nil {
+			fail(err.Error())
+		} else {
+			respond(tiers)
+		}
+	case "event":
+		reply, err := b.Event(ctx, m, strings.TrimSpace(strings.TrimPrefix(message, "event")))
+		if err == ERR_NOT_ADMIN {
+			outcome = "denied"
+		} else if err != nil {
+			fail(err.Error())
+		} else {
+			respond(TruncateMessage(reply))
+		}
+	case "review":
+		if !b.store.CheckAdmin(m.Author.ID) {
+			outcome = "denied"
+			return
+		}
+		reply, err := b.Review(ctx, m, fields[1:])
+		if err != nil {
+			fail(err.Error())
+		} else {
+			respond(TruncateMessage(reply))
 		}
+	default:
+		command, outcome = "unknown", "unrecognized"
 	}
 	return
 
@@ -0,0 +1,210 @@
+package statsbot
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const testAdminID = "221247558008307713"
+
+// newTestStore opens a migrated and seeded sqlite store that is removed with the test
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "stats.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(db)
+	t.Cleanup(func() { s.Close() })
+
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Seed(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestBot creates a bot on a FakeSession with alice and bob registered
+func newTestBot(t *testing.T, cfg *Config) (*Bot, *FakeSession) {
+	t.Helper()
+
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if cfg.BotPrefix == "" {
+		cfg.BotPrefix = "!"
+	}
+
+	s := newTestStore(t)
+	for _, u := range []User{{DiscordID: "1", Name: "alice"}, {DiscordID: "2", Name: "bob"}} {
+		u := u
+		if err := s.InsertUser(&u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := NewFakeSession()
+	b, err := NewWithSession(cfg, s, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, f
+}
+
+// send runs content as a message from the author and returns the replies, embeds are formatted as text
+func send(b *Bot, f *FakeSession, authorID, content string) []string {
+	f.Reset()
+	b.HandleMessage(&discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "m",
+		ChannelID: "c",
+		Content:   content,
+		Author:    &discordgo.User{ID: authorID, Username: "user" + authorID},
+	}})
+
+	replies := []string{}
+	for _, msg := range f.Messages() {
+		if msg.Embed != nil {
+			replies = append(replies, FormatEmbed(msg.Embed))
+		} else {
+			replies = append(replies, msg.Content)
+		}
+	}
+	return replies
+}
+
+func TestAddAndPrint(t *testing.T) {
+	b, f := newTestBot(t, nil)
+
+	if got := send(b, f, "1", "!stats add collector 120"); len(got) != 1 || got[0] != "Successfully added stat!" {
+		t.Fatalf("add replied %q", got)
+	}
+	send(b, f, "2", "!stats add collector 300")
+
+	got := send(b, f, "1", "!stats print collector")
+	if len(got) != 1 {
+		t.Fatalf("print replied %q", got)
+	}
+	bob, alice := strings.Index(got[0], "bob"), strings.Index(got[0], "alice")
+	if bob < 0 || alice < 0 || bob > alice {
+		t.Errorf("print should rank bob above alice, got %q", got[0])
+	}
+}
+
+func TestAddRejectsInvalidValues(t *testing.T) {
+	b, f := newTestBot(t, nil)
+
+	for _, content := range []string{"!stats add collector lots", "!stats add nosuchcategory 5", "!stats add collector 9999999"} {
+		got := send(b, f, "1", content)
+		if len(got) != 1 || strings.HasPrefix(got[0], "Successfully") {
+			t.Errorf("%s replied %q", content, got)
+		}
+	}
+
+	s := b.store
+	c, _ := s.GetCategory("collector")
+	u, _ := s.GetUser("1")
+	if _, err := s.GetStat(c, u); err == nil {
+		t.Error("invalid values should not be saved")
+	}
+}
+
+func TestAdminCommandsAreIgnoredForUsers(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add collector 120")
+
+	if got := send(b, f, "2", "!stats remove collector alice"); len(got) != 0 {
+		t.Errorf("remove by a user replied %q", got)
+	}
+	if got := send(b, f, testAdminID, "!stats remove collector alice"); len(got) != 1 || got[0] != "Successfully removed stat!" {
+		t.Errorf("remove by an admin replied %q", got)
+	}
+}
+
+func TestOtherPrefixesAreIgnored(t *testing.T) {
+	b, f := newTestBot(t, nil)
+
+	for _, content := range []string{"hello", "?stats help", "!stat help"} {
+		if got := send(b, f, "1", content); len(got) != 0 {
+			t.Errorf("%q replied %q", content, got)
+		}
+	}
+	if got := send(b, f, "1", "!stats help"); len(got) != 1 {
+		t.Errorf("help replied %q", got)
+	}
+}
+
+func TestBotsSharingAStore(t *testing.T) {
+	s := newTestStore(t)
+	announcing, err := NewWithSession(&Config{BotPrefix: "!", LeaveGraceDays: 7, AnnounceChannel: "a"}, s, NewFakeSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+	quiet, err := NewWithSession(&Config{BotPrefix: "!"}, s, NewFakeSession())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.leaveGrace != 0 || s.onSave != nil {
+		t.Error("creating a bot changed the store it was given")
+	}
+	if announcing.store.leaveGrace != 7*24*time.Hour || quiet.store.leaveGrace != 0 {
+		t.Errorf("got leave grace %v and %v", announcing.store.leaveGrace, quiet.store.leaveGrace)
+	}
+	if announcing.store.db != quiet.store.db {
+		t.Error("bots sharing a store should share its database")
+	}
+
+	if err := announcing.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := quiet.store.GetCategories(); err != nil {
+		t.Errorf("closing one bot broke the store for the other: %v", err)
+	}
+}
+
+func TestDrainTimesOut(t *testing.T) {
+	b, _ := newTestBot(t, &Config{ShutdownTimeoutSeconds: 1})
+	if !b.begin() {
+		t.Fatal("a new bot refused work")
+	}
+	go func() {
+		<-b.ctx.Done()
+		b.inflight.Done()
+	}()
+
+	if err := b.drain(); err == nil {
+		t.Error("drain should time out while a command is running")
+	}
+}
+
+func TestDrainingRefusesWork(t *testing.T) {
+	b, f := newTestBot(t, &Config{AnnounceChannel: "announce"})
+	if err := b.drain(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := send(b, f, "1", "!stats add collector 100"); len(got) != 0 {
+		t.Errorf("a draining bot replied %q", got)
+	}
+	b.HandleMemberLeave(&discordgo.Member{User: &discordgo.User{ID: "1"}})
+	if u, _ := b.store.GetUserByDiscordID("1"); u.LeftAt != nil {
+		t.Error("a draining bot handled a member event")
+	}
+
+	c, _ := b.store.GetCategory("collector")
+	u, _ := b.store.GetUserByDiscordID("1")
+	b.statSaved(StatChange{Category: c, User: u, Value: 100})
+	b.inflight.Wait()
+	if msgs := f.Messages(); len(msgs) != 0 {
+		t.Errorf("a draining bot announced %+v", msgs)
+	}
+}
@@ -2,61 +2,79 @@ package statsbot
 
 import (
 	"encoding/json"
-	"errors"
-	"flag"
+	"fmt"
 	"io/ioutil"
-	"log"
-	"path"
-	"runtime"
+	"log/slog"
 )
 
-// Config values
-var (
-	Token     string
-	BotPrefix string
-	Server    string
-	test      bool
-
-	config *configStruct
-)
-
-type configStruct struct {
+// Config holds the settings for a single bot instance
+type Config struct {
 	Token     string `json:"Token"`
 	BotPrefix string `json:"BotPrefix"`
 	Server    string `json:"Server"`
+	Database  string `json:"Database"`
+
+	// HTTPAddr is the address the read-only HTTP API and web dashboard listen on, they are off when it is empty
+	HTTPAddr string `json:"HTTPAddr"`
+
+	// MetricsAddr is the address prometheus /metrics is served on, it is off when empty.
+	// Keep it private, it isn't meant to share HTTPAddr.
+	MetricsAddr string `json:"MetricsAddr"`
+
+	// PublicURL is where HTTPAddr can be reached from the internet, category icons are
+	// linked from there when it is set and attached to each message when it is not
+	PublicURL string `json:"PublicURL"`
+
+	// ModLogChannel is the channel ID admin actions are mirrored to, they are only kept in the audit log when it is empty
+	ModLogChannel string `json:"ModLogChannel"`
+
+	// LeaveGraceDays is how many days members who left the server stay on the boards, 0 keeps them there
+	LeaveGraceDays int `json:"LeaveGraceDays"`
+
+	// Submissions are held for moderators in ModLogChannel when they are more than
+	// OutlierZScore standard deviations ahead of everyone else, or improve by more than
+	// OutlierGainFactor times the user's usual weekly gain. 0 turns either check off,
+	// and both are off while ModLogChannel is empty.
+	OutlierZScore     float64 `json:"OutlierZScore"`
+	OutlierGainFactor float64 `json:"OutlierGainFactor"`
+
+	// AnnounceChannel is where tiers, round numbers and overtakes are celebrated, they aren't when it is empty.
+	// Each user is announced at most once every AnnounceCooldownMinutes.
+	AnnounceChannel         string `json:"AnnounceChannel"`
+	AnnounceCooldownMinutes int    `json:"AnnounceCooldownMinutes"`
+
+	// ShutdownTimeoutSeconds is how long the bot waits for in-flight commands once it is
+	// told to stop, before cancelling them. 0 waits 10 seconds.
+	ShutdownTimeoutSeconds int `json:"ShutdownTimeoutSeconds"`
+
+	// LogFormat is text or json and LogLevel is debug, info, warn or error.
+	// Message contents and database queries are only logged at debug.
+	LogFormat string `json:"LogFormat"`
+	LogLevel  string `json:"LogLevel"`
 }
 
-// ReadConfig reads the config file and initializes values using those configs
-func ReadConfig() error {
-	log.Println("Reading from config file...")
+// ReadConfig reads the config file at filename
+func ReadConfig(filename string) (*Config, error) {
+	slog.Info("Reading config file", "file", filename)
 
-	_, filename, _, ok := runtime.Caller(1)
-	if !ok {
-		return errors.New("Unable to get config file location")
-	}
-	file, err := ioutil.ReadFile(path.Join(path.Dir(filename), "../config.json"))
+	file, err := ioutil.ReadFile(filename)
 	if err != nil {
-		log.Println(err.Error())
-		return err
+		return nil, err
 	}
 
+	var config Config
 	err = json.Unmarshal(file, &config)
 	if err != nil {
-		log.Println(err.Error())
-		return err
+		return nil, err
 	}
 
-	Token = config.Token
-	BotPrefix = config.BotPrefix
-	Server = config.Server
-
-	if test {
-		BotPrefix = "?"
-	}
-	return nil
+	return &config, nil
 }
 
-func init() {
-	flag.BoolVar(&test, "t", false, "Run for testing")
-	flag.Parse()
+// DSN returns the database connection string for the config
+func (c *Config) DSN() string {
+	if c.Database != "" {
+		return c.Database
+	}
+	return fmt.Sprintf("%s:%s@/%s?charset=utf8&parseTime=True", "statsuser", "statspass", "stats")
 }
@@ -0,0 +1,228 @@
+package statsbot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const consoleChannelID = "console"
+
+var mentionPattern = regexp.MustCompile(`<@!?(\d+)>`)
+
+// ConsoleSession is a Session that prints messages and embeds to a terminal instead of discord
+type ConsoleSession struct {
+	// Dir is where uploaded files are saved
+	Dir string
+
+	mu  sync.Mutex
+	out io.Writer
+	ids int
+}
+
+// NewConsoleSession creates a ConsoleSession writing to out and saving files in the working directory
+func NewConsoleSession(out io.Writer) *ConsoleSession {
+	return &ConsoleSession{Dir: ".", out: out}
+}
+
+func (c *ConsoleSession) message(channelID, content string) *discordgo.Message {
+	c.ids++
+	return &discordgo.Message{ID: strconv.Itoa(c.ids), ChannelID: channelID, Content: content}
+}
+
+func (c *ConsoleSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := fmt.Fprintln(c.out, content)
+	return c.message(channelID, content), err
+}
+
+func (c *ConsoleSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := fmt.Fprint(c.out, FormatEmbed(embed))
+	return c.message(channelID, ""), err
+}
+
+// ChannelFileSend saves the file in Dir
+func (c *ConsoleSession) ChannelFileSend(channelID, name string, r io.Reader) (*discordgo.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	filename := filepath.Join(c.Dir, filepath.Base(name))
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintln(c.out, "Saved "+filename)
+	return c.message(channelID, ""), err
+}
+
+// ChannelMessageSendComplex prints the content and embed, attachments are listed by name
+func (c *ConsoleSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := ""
+	if data.Content != "" {
+		out += data.Content + "\n"
+	}
+	if data.Embed != nil {
+		out += FormatEmbed(data.Embed)
+	}
+	for _, f := range data.Files {
+		out += "[" + f.Name + "]\n"
+	}
+	for _, row := range data.Components {
+		if r, ok := row.(discordgo.ActionsRow); ok {
+			for _, c := range r.Components {
+				if button, ok := c.(discordgo.Button); ok {
+					out += "(" + button.Label + ") "
+				}
+			}
+			out = strings.TrimSuffix(out, " ") + "\n"
+		}
+	}
+
+	_, err := fmt.Fprint(c.out, out)
+	return c.message(channelID, data.Content), err
+}
+
+// User returns a placeholder user, the console has no discord users to look up
+func (c *ConsoleSession) User(userID string) (*discordgo.User, error) {
+	return &discordgo.User{ID: userID, Username: userID}, nil
+}
+
+func (c *ConsoleSession) Channel(channelID string) (*discordgo.Channel, error) {
+	return nil, fmt.Errorf("Channel %s is not available in the console.", channelID)
+}
+
+func (c *ConsoleSession) Guild(guildID string) (*discordgo.Guild, error) {
+	return nil, fmt.Errorf("Guild %s is not available in the console.", guildID)
+}
+
+func (c *ConsoleSession) GuildMembers(guildID string, after string, limit int) ([]*discordgo.Member, error) {
+	return nil, fmt.Errorf("Guild %s is not available in the console.", guildID)
+}
+
+func (c *ConsoleSession) GuildMembersSearch(guildID, query string, limit int) ([]*discordgo.Member, error) {
+	return nil, fmt.Errorf("Guild %s is not available in the console.", guildID)
+}
+
+// FormatEmbed renders an embed as plain text
+func FormatEmbed(e *discordgo.MessageEmbed) string {
+	var sb strings.Builder
+
+	if e.Author != nil && e.Author.Name != "" {
+		sb.WriteString("== " + e.Author.Name + " ==\n")
+	}
+	if e.Title != "" {
+		sb.WriteString(e.Title + "\n")
+	}
+	if e.Description != "" {
+		sb.WriteString(strings.TrimRight(e.Description, "\n") + "\n")
+	}
+	for _, f := range e.Fields {
+		sb.WriteString("-- " + f.Name + " --\n")
+		sb.WriteString(strings.TrimRight(f.Value, "\n") + "\n")
+	}
+	if e.Footer != nil && e.Footer.Text != "" {
+		sb.WriteString(e.Footer.Text + "\n")
+	}
+
+	return sb.String()
+}
+
+// Console reads commands from in, one per line, and handles them as if author had
+// sent them to discord. Lines without the command prefix get it added. Console
+// returns when ctx is done even while it is waiting for a line.
+func (b *Bot) Console(ctx context.Context, in io.Reader, author *discordgo.User) error {
+	prefix := b.config.BotPrefix + "stats"
+
+	// the scanner blocks on in, so it runs on its own and hands lines over
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	id := 0
+	for {
+		var line string
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok = <-lines:
+		}
+		if !ok {
+			select {
+			case err := <-scanErr:
+				return err
+			default:
+				return ctx.Err()
+			}
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, prefix) {
+			line = prefix + " " + line
+		}
+
+		id++
+		msg := &discordgo.Message{
+			ID:        strconv.Itoa(id),
+			ChannelID: consoleChannelID,
+			Content:   line,
+			Author:    author,
+		}
+		for _, match := range mentionPattern.FindAllStringSubmatch(line, -1) {
+			u, err := b.session.User(match[1])
+			if err == nil {
+				msg.Mentions = append(msg.Mentions, u)
+			}
+		}
+
+		b.HandleMessage(&discordgo.MessageCreate{Message: msg})
+	}
+}
+
+// InteractionRespond prints the response, the console has no buttons to click
+func (c *ConsoleSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error {
+	if resp.Data == nil {
+		return nil
+	}
+	_, err := c.ChannelMessageSend(interaction.ChannelID, resp.Data.Content)
+	return err
+}
@@ -0,0 +1,59 @@
+package statsbot
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestConsoleRunsEachLine(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+	alice := &discordgo.User{ID: "1", Username: "alice"}
+
+	in := strings.NewReader("add collector 100\n\n!stats add collector 200\n")
+	if err := b.Console(context.Background(), in, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := b.store.GetCategory("collector")
+	u, _ := b.store.GetUserByDiscordID("1")
+	if _, total, _ := b.store.GetHistory(u, c.Name, 10, 0); total != 2 {
+		t.Errorf("got %d history entries, want 2", total)
+	}
+}
+
+func TestConsoleStopsWhileWaitingForInput(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	in, w := io.Pipe()
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Console(ctx, in, &discordgo.User{ID: "1", Username: "alice"})
+	}()
+
+	if _, err := io.WriteString(w, "add collector 100\n"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; len(f.Messages()) == 0; i++ {
+		if i == 100 {
+			t.Fatal("the console didn't answer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the console kept waiting for input after ctx was cancelled")
+	}
+}
@@ -0,0 +1,187 @@
+package statsbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ERR_NOTHING_TO_UNDO = errors.New("You have no submissions to undo.")
+	ERR_NOTHING_TO_FIX  = errors.New("You have no value in that category to fix, use `!stats add` instead.")
+	ERR_UNDO_OVERRIDDEN = errors.New("A moderator has changed that value since your last submission, ask them to change it back.")
+)
+
+// UndoLast removes the latest value the user submitted themselves and restores the value before it.
+// The stat is deleted if there was no earlier value. It returns the removed
+// submission and the restored stat, which is nil when the stat was deleted.
+// Values set by moderators or imports can't be undone, and neither can a
+// submission a moderator has changed since.
+func (s *Store) UndoLast(u User) (undone StatHistory, restored *Stat, err error) {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return undone, nil, tx.Error
+	}
+	txs := s.withDB(tx)
+
+	undone, restored, err = txs.undoLast(u)
+	if err != nil {
+		tx.Rollback()
+		return undone, nil, err
+	}
+	return undone, restored, tx.Commit().Error
+}
+
+func (s *Store) undoLast(u User) (undone StatHistory, restored *Stat, err error) {
+	res := s.db.Preload("Category").Where("user_id = ? AND submitted_by = ?", u.ID, u.DiscordID).Order("created_at desc, id desc").First(&undone)
+	if res.Error == gorm.ErrRecordNotFound {
+		return undone, nil, ERR_NOTHING_TO_UNDO
+	} else if res.Error != nil {
+		return undone, nil, res.Error
+	}
+
+	var latest StatHistory
+	res = s.db.Where("user_id = ? AND category_id = ?", u.ID, undone.CategoryID).Order("created_at desc, id desc").First(&latest)
+	if res.Error != nil {
+		return undone, nil, res.Error
+	}
+	if latest.ID != undone.ID {
+		return undone, nil, ERR_UNDO_OVERRIDDEN
+	}
+
+	res = s.db.Delete(&undone)
+	if res.Error != nil {
+		return undone, nil, res.Error
+	}
+
+	var previous StatHistory
+	res = s.db.Where("user_id = ? AND category_id = ?", u.ID, undone.CategoryID).Order("created_at desc, id desc").First(&previous)
+	if res.Error == gorm.ErrRecordNotFound {
+		err = s.deleteStat(undone.Category, u)
+		if err == gorm.ErrRecordNotFound {
+			err = nil
+		}
+		return undone, nil, err
+	} else if res.Error != nil {
+		return undone, nil, res.Error
+	}
+
+	stat := Stat{}
+	res = s.db.Where(Stat{CategoryID: undone.CategoryID, UserID: u.ID}).Assign(map[string]interface{}{"value": previous.Value}).FirstOrCreate(&stat)
+	return undone, &stat, res.Error
+}
+
+// CorrectStat replaces the user's value for the category, marking it as a correction of the previous value.
+// by is the discord ID of whoever sent the correction.
+func (s *Store) CorrectStat(c Category, u User, v int, by string) error {
+	if !c.Validate(v) {
+		return ERR_INVALID_VALUE
+	}
+
+	_, err := s.GetStat(c, u)
+	if err == gorm.ErrRecordNotFound {
+		return ERR_NOTHING_TO_FIX
+	} else if err != nil {
+		return err
+	}
+
+	return s.saveStat(c, u, v, true, by)
+}
+
+// Undo reverts the latest submission of the author of m
+func (b *Bot) Undo(m *discordgo.MessageCreate) (string, error) {
+	user, err := b.store.GetUserByDiscordID(m.Author.ID)
+	if err == gorm.ErrRecordNotFound {
+		return "", ERR_NOTHING_TO_UNDO
+	} else if err != nil {
+		return "", err
+	}
+
+	undone, restored, err := b.store.UndoLast(user)
+	if err != nil {
+		return "", err
+	}
+
+	c := undone.Category
+	if restored == nil {
+		return fmt.Sprintf("Removed your %s value of %s.", c.FullName, c.FormatValue(undone.Value)), nil
+	}
+	return fmt.Sprintf("Removed your %s value of %s, it is back to %s.", c.FullName, c.FormatValue(undone.Value), c.FormatValue(restored.Value)), nil
+}
+
+// RemoveStat deletes a user's value for a category, args are the category and optionally the user
+func (b *Bot) RemoveStat(ctx context.Context, m *discordgo.MessageCreate, args []string) error {
+	if len(args) < 1 || len(args) > 2 || args[0] == "" {
+		return errors.New("Use `!stats remove {category} [user]`.")
+	}
+
+	category, err := b.store.GetCategory(strings.ToLower(args[0]))
+	if err != nil {
+		return err
+	}
+
+	var user User
+	if len(args) == 2 {
+		user, err = b.ResolveUser(ctx, b.session, m, args[1])
+	} else {
+		user, err = b.store.GetUserByDiscordID(m.Author.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	old, err := b.store.GetStat(category, user)
+	if err == gorm.ErrRecordNotFound {
+		return fmt.Errorf("%s has no %s value.", user.Name, category.FullName)
+	} else if err != nil {
+		return err
+	}
+
+	err = b.store.DeleteStat(category, user)
+	if err != nil {
+		return err
+	}
+
+	b.audit(ctx, m, AuditEntry{Action: "remove", TargetID: user.DiscordID, Target: user.Name, Category: category.Name, OldValue: category.FormatValue(old.Value)})
+	return nil
+}
+
+// FixStat replaces the author's value for a category without counting the change as progress
+func (b *Bot) FixStat(ctx context.Context, m *discordgo.MessageCreate, args []string) error {
+	if len(args) != 2 {
+		return errors.New("Use `!stats fix {category} {value}`.")
+	}
+
+	category, err := b.store.GetCategory(strings.ToLower(args[0]))
+	if err != nil {
+		return err
+	}
+
+	user, err := b.store.GetUserByDiscordID(m.Author.ID)
+	if err != nil {
+		return err
+	}
+
+	value, err := category.ParseValue(args[1])
+	if err != nil {
+		return err
+	}
+
+	if !b.store.CheckAdmin(m.Author.ID) {
+		err = b.enforceRules(ctx, m, category, user, value, true, b.store.CheckCorrection(category, user, value))
+		if err != nil {
+			return err
+		}
+
+		err = b.holdOutlier(ctx, m, category, user, value, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.store.CorrectStat(category, user, value, m.Author.ID)
+}
@@ -0,0 +1,91 @@
+package statsbot
+
+import (
+	"testing"
+)
+
+func collectorValue(t *testing.T, b *Bot, user string) (int, bool) {
+	t.Helper()
+
+	c, _ := b.store.GetCategory("collector")
+	u, err := b.store.GetUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := b.store.GetStat(c, u)
+	return stat.Value, err == nil
+}
+
+func TestUndo(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add collector 100")
+	send(b, f, "1", "!stats add collector 200")
+
+	got := send(b, f, "1", "!stats undo")
+	if v, _ := collectorValue(t, b, "alice"); v != 100 {
+		t.Errorf("undo replied %q and left %d, want 100", got, v)
+	}
+
+	send(b, f, "1", "!stats undo")
+	if _, ok := collectorValue(t, b, "alice"); ok {
+		t.Error("undoing the only submission should delete the stat")
+	}
+	if got := send(b, f, "1", "!stats undo"); len(got) != 1 || got[0] != ERR_NOTHING_TO_UNDO.Error() {
+		t.Errorf("undo with nothing left replied %q", got)
+	}
+}
+
+func TestUndoKeepsModeratorChanges(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add collector 900")
+	send(b, f, testAdminID, "!stats add collector alice 100")
+
+	if got := send(b, f, "1", "!stats undo"); len(got) != 1 || got[0] != ERR_UNDO_OVERRIDDEN.Error() {
+		t.Errorf("undo replied %q", got)
+	}
+	if v, _ := collectorValue(t, b, "alice"); v != 100 {
+		t.Errorf("alice has %d, want the moderator's 100", v)
+	}
+}
+
+func TestRemoveClearsHistory(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add collector 100")
+	send(b, f, "1", "!stats add collector 900")
+	send(b, f, testAdminID, "!stats remove collector alice")
+
+	if got := send(b, f, "1", "!stats undo"); len(got) != 1 || got[0] != ERR_NOTHING_TO_UNDO.Error() {
+		t.Errorf("undo after remove replied %q", got)
+	}
+	if _, ok := collectorValue(t, b, "alice"); ok {
+		t.Error("undo brought back a removed stat")
+	}
+
+	u, _ := b.store.GetUser("alice")
+	if _, total, err := b.store.GetHistory(u, "collector", 10, 0); err != nil || total != 0 {
+		t.Errorf("removed stat still has %d history rows, %v", total, err)
+	}
+}
+
+func TestZeroValues(t *testing.T) {
+	b, f := newTestBot(t, nil)
+
+	send(b, f, "1", "!stats add collector 0")
+	send(b, f, "1", "!stats add collector 50")
+	send(b, f, "1", "!stats fix collector 0")
+	if v, _ := collectorValue(t, b, "alice"); v != 0 {
+		t.Errorf("fix to 0 left %d", v)
+	}
+
+	send(b, f, "1", "!stats add collector 70")
+	send(b, f, "1", "!stats undo")
+	if v, _ := collectorValue(t, b, "alice"); v != 0 {
+		t.Errorf("undo back to 0 left %d", v)
+	}
+
+	send(b, f, "2", "!stats add collector 30")
+	send(b, f, "2", "!stats add collector 0")
+	if v, _ := collectorValue(t, b, "bob"); v != 0 {
+		t.Errorf("add 0 left %d", v)
+	}
+}
@@ -0,0 +1,235 @@
+package statsbot
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxImportSize is the largest CSV attachment the bot will download
+const maxImportSize = 5 << 20
+
+var (
+	ERR_NO_USER_COLUMN       = errors.New("The first column must be the user's discord ID or name.")
+	ERR_ATTACHMENT_TOO_LARGE = errors.New("The attachment is too large, it can be at most 5MB.")
+)
+
+// ImportRowError is a problem with one row of a CSV import
+type ImportRowError struct {
+	Row    int
+	Column string
+	Err    error
+}
+
+func (e ImportRowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %s", e.Row, e.Err.Error())
+	}
+	return fmt.Sprintf("row %d, %s: %s", e.Row, e.Column, e.Err.Error())
+}
+
+// ImportReport is the result of a CSV import
+type ImportReport struct {
+	DryRun bool
+	Rows   int
+	Stats  int
+	Errors []ImportRowError
+}
+
+func (r *ImportReport) String() string {
+	verb := "Imported"
+	if r.DryRun {
+		verb = "Dry run: would import"
+	}
+
+	message := fmt.Sprintf("%s %d stats from %d rows, %d rows skipped.\n", verb, r.Stats, r.Rows, r.skipped())
+	for _, err := range r.Errors {
+		message += err.Error() + "\n"
+	}
+	return message
+}
+
+func (r *ImportReport) skipped() int {
+	rows := map[int]bool{}
+	for _, err := range r.Errors {
+		rows[err.Row] = true
+	}
+	return len(rows)
+}
+
+// ImportCSV imports historical stats from a CSV file. The header row holds
+// "user" followed by category names; every other row holds a user's discord ID
+// or name followed by their values. Empty cells are skipped and a row with any
+// error is skipped entirely. With dryRun nothing is written.
+func (s *Store) ImportCSV(r io.Reader, dryRun bool) (*ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 2 {
+		return nil, ERR_NO_USER_COLUMN
+	}
+	switch strings.ToLower(strings.TrimSpace(header[0])) {
+	case "user", "name", "discord_id", "discordid", "id":
+	default:
+		return nil, ERR_NO_USER_COLUMN
+	}
+
+	columns := make([]Category, len(header))
+	for i, name := range header[1:] {
+		category, err := s.GetCategory(strings.ToLower(strings.TrimSpace(name)))
+		if err != nil {
+			return nil, fmt.Errorf("Unknown category %s.", name)
+		}
+		columns[i+1] = category
+	}
+
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	txs := s.withDB(tx)
+	// imports are bulk loads, they aren't announced
+	txs.onSave = nil
+
+	report := &ImportReport{DryRun: dryRun}
+	row := 1
+	for {
+		record, err := reader.Read()
+		row++
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		report.Rows++
+
+		rowErrs, stats := txs.importRow(row, record, columns)
+		report.Errors = append(report.Errors, rowErrs...)
+		if len(rowErrs) > 0 {
+			continue
+		}
+
+		for _, stat := range stats {
+			if !dryRun {
+				if err := txs.NewStat(stat.Category, stat.User, stat.Value); err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+			}
+			report.Stats++
+		}
+	}
+
+	if dryRun {
+		tx.Rollback()
+		return report, nil
+	}
+	return report, tx.Commit().Error
+}
+
+// importRow checks one CSV row and returns the stats it holds
+func (s *Store) importRow(row int, record []string, columns []Category) (errs []ImportRowError, stats []Stat) {
+	name := strings.TrimSpace(record[0])
+	if name == "" {
+		return []ImportRowError{{Row: row, Err: errors.New("missing user")}}, nil
+	}
+
+	user, err := s.GetUser(name)
+	if err != nil {
+		return []ImportRowError{{Row: row, Err: fmt.Errorf("unknown user %s", name)}}, nil
+	}
+
+	if len(record) > len(columns) {
+		errs = append(errs, ImportRowError{Row: row, Err: errors.New("too many columns")})
+	}
+
+	for i := 1; i < len(record) && i < len(columns); i++ {
+		cell := strings.TrimSpace(record[i])
+		if cell == "" {
+			continue
+		}
+
+		category := columns[i]
+		value, err := category.ParseValue(cell)
+		if err != nil {
+			errs = append(errs, ImportRowError{Row: row, Column: category.Name, Err: fmt.Errorf("%q is not a valid %s value", cell, category.ValueType())})
+			continue
+		}
+		if !category.Validate(value) {
+			errs = append(errs, ImportRowError{Row: row, Column: category.Name, Err: fmt.Errorf("%s is outside %d-%d", category.FormatValue(value), category.Min, category.Max)})
+			continue
+		}
+
+		stats = append(stats, Stat{Category: category, User: user, Value: value})
+	}
+
+	return errs, stats
+}
+
+// downloadAttachment fetches a discord attachment
+func downloadAttachment(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unable to download attachment: %s", resp.Status)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{&sizeLimitReader{io.LimitReader(resp.Body, maxImportSize+1), maxImportSize}, resp.Body}, nil
+}
+
+// sizeLimitReader fails with ERR_ATTACHMENT_TOO_LARGE once more than n bytes are read,
+// so a large file is never mistaken for a shorter one
+type sizeLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return 0, ERR_ATTACHMENT_TOO_LARGE
+	}
+	return n, err
+}
+
+// ImportAttachment imports the CSV file attached to the message
+func (b *Bot) ImportAttachment(ctx context.Context, m *discordgo.MessageCreate, dryRun bool) (*ImportReport, error) {
+	if len(m.Attachments) == 0 {
+		return nil, errors.New("Attach a CSV file to import.")
+	}
+	if m.Attachments[0].Size > maxImportSize {
+		return nil, ERR_ATTACHMENT_TOO_LARGE
+	}
+
+	body, err := downloadAttachment(ctx, m.Attachments[0].URL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return b.store.ImportCSV(body, dryRun)
+}
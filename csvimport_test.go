@@ -0,0 +1,40 @@
+package statsbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+
+	report, err := b.store.ImportCSV(strings.NewReader("user,collector,jogger\nalice,120,5.5\nbob,lots,3\ncarol,1,1\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 3 || report.Stats != 2 || len(report.Errors) != 2 {
+		t.Errorf("got %d rows, %d stats and errors %v", report.Rows, report.Stats, report.Errors)
+	}
+
+	c, _ := b.store.GetCategory("jogger")
+	u, _ := b.store.GetUser("alice")
+	if stat, err := b.store.GetStat(c, u); err != nil || c.FormatValue(stat.Value) != "5.5 km" {
+		t.Errorf("alice's jogger value is %v, %v", stat.Value, err)
+	}
+}
+
+func TestImportCSVTooLarge(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+
+	data := "user,collector\nalice,120\nbob,12345\n"
+	r := &sizeLimitReader{strings.NewReader(data), int64(len(data) - 3)}
+	if _, err := b.store.ImportCSV(r, false); err != ERR_ATTACHMENT_TOO_LARGE {
+		t.Fatalf("got %v, want ERR_ATTACHMENT_TOO_LARGE", err)
+	}
+
+	c, _ := b.store.GetCategory("collector")
+	u, _ := b.store.GetUser("alice")
+	if _, err := b.store.GetStat(c, u); err == nil {
+		t.Error("a cut off import should not save any rows")
+	}
+}
@@ -1,29 +1,58 @@
 package statsbot
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
 )
 
-var (
-	IMAGE_LOCATION = os.Getenv("GOPATH") + "/src/github.com/haynesherway/statsbot/img/"
-	IMAGE_URL      = "https://github.com/haynesherway/statsbot/blob/master/img/"
-	statsDB        *gorm.DB
-)
+// Store is the stats database used by a bot
+type Store struct {
+	db      *gorm.DB
+	metrics *storeMetrics
+
+	// leaveGrace is how long users who left the server stay on the boards, they are never hidden when it is 0
+	leaveGrace time.Duration
+
+	// onSave is called after NewStat saves a value, corrections don't count
+	onSave func(StatChange)
+}
 
 type Category struct {
 	ID          int    `gorm:"AUTO_INCREMENT" gorm:"primary_key"`
 	Name        string `gorm:"size:25;unique;index"`
 	FullName    string `gorm:"size:25"`
-	Min         int    `gorm:"DEFAULT:0`
-	Max         int    `gorm:"DEFAULT:100000`
+	Min         int    `gorm:"DEFAULT:0"`
+	Max         int    `gorm:"DEFAULT:100000"`
 	Order       int    `gorm:"DEFAULT:0"`
 	OptionValue bool   `gorm:"DEFAULT:false"`
 	Image       string
+
+	// Type is one of ValueTypes, values are stored multiplied by 10^Decimals
+	Type     string `gorm:"size:10;DEFAULT:'integer'"`
+	Decimals int    `gorm:"DEFAULT:0"`
+
+	// LowerIsBetter ranks the smallest value first, for things like clear times
+	LowerIsBetter bool `gorm:"DEFAULT:false"`
+
+	// Monotonic values never get worse, MaxDailyGain limits how fast they improve
+	// in displayed units, and Ceiling names a category the value can't exceed.
+	// Values breaking these rules are rejected, or held for review if Review is set.
+	Monotonic    bool   `gorm:"DEFAULT:false"`
+	MaxDailyGain int    `gorm:"DEFAULT:0"`
+	Ceiling      string `gorm:"size:25"`
+	Review       bool   `gorm:"DEFAULT:false"`
+
+	// Medal tier thresholds in displayed units, 0 means the category has no such tier
+	Bronze   int `gorm:"DEFAULT:0"`
+	Silver   int `gorm:"DEFAULT:0"`
+	Gold     int `gorm:"DEFAULT:0"`
+	Platinum int `gorm:"DEFAULT:0"`
 }
 
 type User struct {
@@ -32,6 +61,20 @@ type User struct {
 	Name      string `gorm:"size:20;index"`
 	Admin     bool   `gorm:"DEFAULT:false"`
 	Active    bool   `gorm:"DEFAULT:1"`
+
+	// CustomName is set once the user picks their own name, it then stops following their nickname
+	CustomName bool `gorm:"DEFAULT:false"`
+
+	// LeftAt is when the user left the server, nil while they are a member
+	LeftAt *time.Time
+
+	// NoAnnounce keeps the user out of milestone and overtake announcements
+	NoAnnounce bool `gorm:"DEFAULT:false"`
+
+	// Team is one of Teams or empty, CustomTeam is set once the user picks it
+	// themselves and it then stops following their server roles
+	Team       string `gorm:"size:10;index"`
+	CustomTeam bool   `gorm:"DEFAULT:false"`
 }
 
 type Stat struct {
@@ -39,23 +82,39 @@ type Stat struct {
 	Category      Category
 	CategoryID    int `gorm:"unique_index:user_stat"`
 	User          User
-	UserID        int `gorm:"unique_index:user_stat" sql:"type:bigint REFERENCES user(id)"`
-	Value         int
+	UserID        int    `gorm:"unique_index:user_stat" sql:"type:bigint REFERENCES user(id)"`
+	Value         int    `gorm:"type:bigint"`
 	OptionalValue string `gorm:"DEFAULT:NULL"`
 	Verified      bool   `gorm:"DEFAULT:true"`
 }
 
+// StatHistory is a single submitted value, Stat only keeps the latest one.
+// Correction is set when the value fixed a mistake in the one before it rather than being progress.
+type StatHistory struct {
+	ID         int `gorm:"primary_key"`
+	CreatedAt  time.Time
+	Category   Category
+	CategoryID int `gorm:"index:history_user_category"`
+	User       User
+	UserID     int  `gorm:"index:history_user_category"`
+	Value      int  `gorm:"type:bigint"`
+	Correction bool `gorm:"DEFAULT:false"`
+
+	// SubmittedBy is the discord ID of whoever sent the value, it is empty for imports and the admin tool
+	SubmittedBy string `gorm:"size:20"`
+}
+
 var categories = []Category{
-	{Name: "jogger", FullName: "Jogger", Min: 0, Max: 50000, Order: 1},
-	{Name: "collector", FullName: "Collector", Min: 0, Max: 500000, Order: 2},
-	{Name: "scientist", FullName: "Scientist", Min: 0, Max: 50000, Order: 3},
-	{Name: "breeder", FullName: "Breeder", Min: 0, Max: 50000, Order: 4},
-	{Name: "backpacker", FullName: "Backpacker", Min: 0, Max: 500000, Order: 5},
-	{Name: "battlegirl", FullName: "Battle Girl", Min: 0, Max: 50000, Order: 6},
-	{Name: "berrymaster", FullName: "Berry Master", Min: 0, Max: 500000, Order: 7},
-	{Name: "gymleader", FullName: "Gym Leader", Min: 0, Max: 500000, Order: 8},
-	{Name: "raidchampion", FullName: "Raid Champion", Min: 0, Max: 10000, Order: 9},
-	{Name: "legendaryraid", FullName: "Legendary Raid", Min: 0, Max: 10000, Order: 10},
+	{Name: "jogger", FullName: "Jogger", Min: 0, Max: 50000, Order: 1, Type: TypeDistance, Decimals: 1, Bronze: 10, Silver: 100, Gold: 1000, Platinum: 10000},
+	{Name: "collector", FullName: "Collector", Min: 0, Max: 500000, Order: 2, Bronze: 30, Silver: 500, Gold: 2000, Platinum: 50000},
+	{Name: "scientist", FullName: "Scientist", Min: 0, Max: 50000, Order: 3, Bronze: 3, Silver: 20, Gold: 200, Platinum: 2000},
+	{Name: "breeder", FullName: "Breeder", Min: 0, Max: 50000, Order: 4, Bronze: 10, Silver: 100, Gold: 1000, Platinum: 2500},
+	{Name: "backpacker", FullName: "Backpacker", Min: 0, Max: 500000, Order: 5, Bronze: 100, Silver: 1000, Gold: 2000, Platinum: 50000},
+	{Name: "battlegirl", FullName: "Battle Girl", Min: 0, Max: 50000, Order: 6, Bronze: 10, Silver: 100, Gold: 1000, Platinum: 4000},
+	{Name: "berrymaster", FullName: "Berry Master", Min: 0, Max: 500000, Order: 7, Bronze: 10, Silver: 100, Gold: 1000, Platinum: 15000},
+	{Name: "gymleader", FullName: "Gym Leader", Min: 0, Max: 500000, Order: 8, Bronze: 10, Silver: 100, Gold: 1000, Platinum: 15000},
+	{Name: "raidchampion", FullName: "Raid Champion", Min: 0, Max: 10000, Order: 9, Bronze: 10, Silver: 100, Gold: 1000, Platinum: 2000},
+	{Name: "legendaryraid", FullName: "Legendary Raid", Min: 0, Max: 10000, Order: 10, Bronze: 10, Silver: 100, Gold: 1000, Platinum: 2000},
 	{Name: "goldgyms", FullName: "Gold Gym Badges", Min: 0, Max: 50000, Order: 11},
 	{Name: "totalxp", FullName: "Total XP", Min: 0, Max: 100000000, Order: 12},
 }
@@ -66,41 +125,103 @@ var admins = []User{
 	{DiscordID: "162112652691111937", Name: "Alletzhauser", Admin: true},
 }
 
-func InitDB() (err error) {
-	username := "statsuser"
-	password := "statspass"
+// OpenStore connects to the mysql database at dsn and migrates the tables
+func OpenStore(dsn string) (*Store, error) {
+	db, err := gorm.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
 
-	statsDB, err = gorm.Open("mysql", fmt.Sprintf("%s:%s@/%s?charset=utf8&parseTime=True", username, password, "stats"))
+	err = db.DB().Ping()
 	if err != nil {
-		return err
+		db.Close()
+		return nil, err
 	}
 
-	err = statsDB.DB().Ping()
+	s := NewStore(db)
+	err = s.Migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewStore wraps an already open database
+func NewStore(db *gorm.DB) *Store {
+	db.SetLogger(gormLogger{})
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		db = db.LogMode(true)
+	}
+
+	metrics := newStoreMetrics()
+	return &Store{db: metrics.instrument(db), metrics: metrics}
+}
+
+// withDB returns a store sharing s's metrics that runs its queries on db, usually a transaction begun on s
+func (s *Store) withDB(db *gorm.DB) *Store {
+	return &Store{db: db, metrics: s.metrics, leaveGrace: s.leaveGrace, onSave: s.onSave}
+}
+
+// Close closes the database connection
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Migrate creates or updates the tables
+func (s *Store) Migrate() (err error) {
+	slog.Info("Migrating database tables")
+	err = s.db.AutoMigrate(&Category{}, &User{}, &Stat{}, &StatHistory{}, &CategoryImage{}, &AuditEntry{}, &PendingStat{}, &Event{}, &EventSnapshot{}).Error
 	if err != nil {
 		return err
 	}
+	return s.widenValues()
+}
 
-	err = createDatabase()
+// widenValues makes the value columns bigint in databases created when they
+// were int, AutoMigrate never changes the type of an existing column. Values
+// are scaled by 10^Decimals so they can outgrow an int. sqlite integers are
+// always 64 bit.
+func (s *Store) widenValues() error {
+	if s.db.Dialect().GetName() == "sqlite3" {
+		return nil
+	}
 
-	return err
+	for _, model := range []interface{}{&Stat{}, &StatHistory{}, &PendingStat{}, &EventSnapshot{}} {
+		err := s.db.Model(model).ModifyColumn("value", "bigint").Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func createDatabase() (err error) {
-	log.Println("Creating tables...")
-	statsDB.AutoMigrate(&Category{}, &User{}, &Stat{})
-	/*statsDB.CreateTable(&Category{})
+// Seed adds the default categories and admins that are missing
+func (s *Store) Seed() error {
 	for _, category := range categories {
-		statsDB.Create(&category)
+		category := category
+		tiers := map[string]interface{}{"bronze": category.Bronze, "silver": category.Silver, "gold": category.Gold, "platinum": category.Platinum}
+		res := s.db.Where("name = ?", category.Name).FirstOrCreate(&category)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		// categories created before tiers existed get the medal's thresholds
+		if len(category.Tiers()) == 0 && tiers["bronze"] != 0 {
+			res = s.db.Model(&category).Updates(tiers)
+			if res.Error != nil {
+				return res.Error
+			}
+		}
 	}
-	log.Println("Creating User table...")
-	statsDB.CreateTable(&User{})
 	for _, user := range admins {
-		statsDB.Create(&user)
+		user := user
+		res := s.db.Where("discord_id = ?", user.DiscordID).FirstOrCreate(&user)
+		if res.Error != nil {
+			return res.Error
+		}
 	}
-	log.Println("Creating Stat table...")
-	statsDB.CreateTable(&Stat{})
-	*/
-
 	return nil
 }
 
@@ -108,31 +229,22 @@ func (Category) TableName() string {
 	return "Category"
 }
 
-func GetCategory(s string) (category Category, err error) {
-	res := statsDB.Where("name = ?", s).First(&category)
-	if category.Image == "" {
-		category.Image = IMAGE_URL + category.Name + ".png?raw=true"
-	}
+func (s *Store) GetCategory(name string) (category Category, err error) {
+	res := s.db.Where("name = ?", name).First(&category)
 
 	return category, res.Error
 }
 
-func GetCategories() ([]Category, error) {
+func (s *Store) GetCategories() ([]Category, error) {
 	var categories []Category
-	res := statsDB.Order("name asc").Find(&categories)
-
-	for i, category := range categories {
-		if category.Image == "" {
-			categories[i].Image = IMAGE_URL + category.Name + ".png?raw=true"
-		}
-	}
+	res := s.db.Order("name asc").Find(&categories)
 
 	return categories, res.Error
 }
 
-func PrintCategories() (string, error) {
+func (s *Store) PrintCategories() (string, error) {
 	var categories []Category
-	res := statsDB.Order("name asc").Find(&categories)
+	res := s.db.Order("name asc").Find(&categories)
 	if res.Error != nil {
 		return "", res.Error
 	}
@@ -145,67 +257,166 @@ func PrintCategories() (string, error) {
 	return message, nil
 }
 
-func (c *Category) Validate(value int) bool {
-	if value < c.Min {
-		return false
-	} else if value > c.Max {
-		return false
-	}
+// AddCategory creates a new category
+func (s *Store) AddCategory(c *Category) error {
+	return s.db.Create(c).Error
+}
+
+// UpdateCategory saves the changes made to an existing category
+func (s *Store) UpdateCategory(c *Category) error {
+	return s.db.Save(c).Error
+}
 
-	return true
+// AddStat validates and sets the user's value without recording who submitted it
+func (s *Store) AddStat(c Category, u User, v int) error {
+	return s.SubmitStat(c, u, v, "")
 }
 
-func (c Category) AddStat(u User, v int) error {
+// SubmitStat validates and sets the user's value, by is the discord ID of whoever sent it
+func (s *Store) SubmitStat(c Category, u User, v int, by string) error {
 	if !c.Validate(v) {
 		return ERR_INVALID_VALUE
 	}
 
-	err := NewStat(c, u, v)
-	if err != nil {
-		return err
+	return s.saveStat(c, u, v, false, by)
+}
+
+// valueOrder is the ORDER BY clause that puts the category's best value first
+func (c Category) valueOrder() string {
+	if c.LowerIsBetter {
+		return "value asc"
 	}
-	return nil
+	return "value desc"
 }
 
-func (c *Category) GetAll() (stats []Stat, err error) {
-	res := statsDB.Model(&c).Preload("User").Order("value desc").Related(&stats)
+// Better reports whether value a ranks ahead of value b in the category
+func (c Category) Better(a, b int) bool {
+	if c.LowerIsBetter {
+		return a < b
+	}
+	return a > b
+}
+
+// Gain is how much the value improved going from one value to another, it is
+// negative when the value got worse
+func (c Category) Gain(from, to int) int {
+	if c.LowerIsBetter {
+		return from - to
+	}
+	return to - from
+}
+
+// GetStat returns the user's current stat for the category
+func (s *Store) GetStat(c Category, u User) (stat Stat, err error) {
+	res := s.db.Where("category_id = ? AND user_id = ?", c.ID, u.ID).First(&stat)
+	return stat, res.Error
+}
+
+func (s *Store) GetAll(c Category) (stats []Stat, err error) {
+	q := s.db
+	if s.leaveGrace > 0 {
+		left := s.db.Model(&User{}).Select("id").Where("left_at < ?", time.Now().Add(-s.leaveGrace)).SubQuery()
+		q = q.Where("user_id NOT IN ?", left)
+	}
+
+	res := q.Model(&c).Preload("User").Order(c.valueOrder()).Related(&stats)
 
 	return stats, res.Error
 }
 
-func (c *Category) GetUserRank(uid int) (string, error) {
-	stats, err := c.GetAll()
+func (s *Store) GetUserRank(c Category, uid int) (string, error) {
+	rank, total, err := s.UserRank(c, uid)
 	if err != nil {
 		return "0", err
 	}
 
-	rank := 1
+	if rank == 0 {
+		return fmt.Sprintf("%v/%v", "-", total), nil
+	}
+	return fmt.Sprintf("%v/%v", rank, total), nil
+}
+
+// CategoryRank is a user's standing in one category, Stat is nil if the user has no value
+type CategoryRank struct {
+	Category Category
+	Stat     *Stat
+	Rank     int
+	Total    int
+}
+
+// GetRanks returns the user's standing in every category
+func (s *Store) GetRanks(u User) ([]CategoryRank, error) {
+	categories, err := s.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.GetStats(u)
+	if err != nil {
+		return nil, err
+	}
+	byCategory := map[int]Stat{}
 	for _, stat := range stats {
+		byCategory[stat.CategoryID] = stat
+	}
+
+	ranks := []CategoryRank{}
+	for _, c := range categories {
+		rank, total, err := s.UserRank(c, u.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		cr := CategoryRank{Category: c, Rank: rank, Total: total}
+		if stat, ok := byCategory[c.ID]; ok {
+			cr.Stat = &stat
+		}
+		ranks = append(ranks, cr)
+	}
+	return ranks, nil
+}
+
+// UserRank returns the user's position in the category and the number of ranked users, rank is 0 if the user has no stat
+func (s *Store) UserRank(c Category, uid int) (rank int, total int, err error) {
+	stats, err := s.GetAll(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i, stat := range stats {
 		if stat.UserID == uid {
-			return fmt.Sprintf("%v/%v", rank, len(stats)), nil
+			return i + 1, len(stats), nil
 		}
-		rank++
 	}
-	return fmt.Sprintf("%v/%v", "-", len(stats)), nil
+	return 0, len(stats), nil
 }
 
-func (c *Category) PrintStats() (string, error) {
+// PrintStats writes the category's leaderboard, when team isn't empty only its users are listed, with their overall rank
+func (s *Store) PrintStats(c Category, team string) (string, error) {
 	var message string
 
-	stats, err := c.GetAll()
+	stats, err := s.GetAll(c)
 	if err != nil {
 		return message, err
 	}
 
-	rank := 1
 	message = ""
-	for _, stat := range stats {
+	for i, stat := range stats {
+		// a team's board keeps everyone's overall rank, like the web and API filters
+		if team != "" && stat.User.Team != team {
+			continue
+		}
+		rank := i + 1
+
+		tier := ""
+		if t := c.Tier(stat.Value); t.Name != "" {
+			tier = " " + t.Emoji
+		}
 		if !stat.User.Active {
-			message += fmt.Sprintf("%d. *%s %d*\n", rank, stat.User.Name, stat.Value)
+			message += fmt.Sprintf("%d. *%s %s*%s\n", rank, stat.User.Name, c.FormatValue(stat.Value), tier)
 		} else {
-			message += fmt.Sprintf("%d. %s %d\n", rank, stat.User.Name, stat.Value)
+			message += fmt.Sprintf("%d. %s %s%s\n", rank, stat.User.Name, c.FormatValue(stat.Value), tier)
 		}
-		rank++
 	}
 
 	return message, err
@@ -215,15 +426,53 @@ func (User) TableName() string {
 	return "User"
 }
 
-func (u *User) Insert() error {
-	res := statsDB.Where("discord_id = ?", u.DiscordID).Assign(User{Name: u.Name}).FirstOrCreate(&u)
+// InsertUser adds the user, or renames the user with the same discord ID. A name
+// the user picked is kept unless u.CustomName is set, and a name another user
+// goes by gets a number added, or is ERR_NAME_TAKEN when it was picked.
+func (s *Store) InsertUser(u *User) error {
+	var existing User
+	res := s.db.Where("discord_id = ?", u.DiscordID).First(&existing)
+	if res.Error != nil && res.Error != gorm.ErrRecordNotFound {
+		return res.Error
+	}
+	found := res.Error == nil
+	if found && existing.CustomName && !u.CustomName {
+		*u = existing
+		return nil
+	}
 
-	return res.Error
+	name, err := s.freeName(&existing, u.Name)
+	if err != nil {
+		return err
+	}
+	if name == "" || (u.CustomName && name != u.Name) {
+		return ERR_NAME_TAKEN
+	}
+
+	if !found {
+		u.Name = name
+		return s.db.Create(u).Error
+	}
+	existing.Name, existing.CustomName = name, u.CustomName
+	*u = existing
+	return s.db.Model(u).Updates(map[string]interface{}{"name": name, "custom_name": u.CustomName}).Error
+}
+
+// SetAdmin grants or revokes admin rights for the user
+func (s *Store) SetAdmin(u *User, admin bool) error {
+	u.Admin = admin
+	return s.db.Model(u).Update("admin", admin).Error
 }
 
-func (u *User) GetStats() ([]Stat, error) {
+// SetActive marks the user active or inactive
+func (s *Store) SetActive(u *User, active bool) error {
+	u.Active = active
+	return s.db.Model(u).Update("active", active).Error
+}
+
+func (s *Store) GetStats(u User) ([]Stat, error) {
 	var stats []Stat
-	res := statsDB.Model(&u).Preload("Category").Related(&stats)
+	res := s.db.Model(&u).Preload("Category").Related(&stats)
 	if res.Error != nil {
 		return stats, res.Error
 	}
@@ -231,9 +480,9 @@ func (u *User) GetStats() ([]Stat, error) {
 	return stats, nil
 }
 
-func PrintUsers() (string, error) {
+func (s *Store) PrintUsers() (string, error) {
 	var users []User
-	res := statsDB.Order("name asc").Find(&users)
+	res := s.db.Order("name asc").Find(&users)
 	if res.Error != nil {
 		return "", res.Error
 	}
@@ -250,9 +499,17 @@ func PrintUsers() (string, error) {
 	return message, nil
 }
 
-func GetActiveUsers() ([]User, error) {
+// SearchUsers returns the users whose name contains q
+func (s *Store) SearchUsers(q string) ([]User, error) {
+	var users []User
+	q = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(q)
+	res := s.db.Where("name LIKE ? ESCAPE '!'", "%"+q+"%").Order("name asc").Find(&users)
+	return users, res.Error
+}
+
+func (s *Store) GetActiveUsers() ([]User, error) {
 	var users []User
-	res := statsDB.Where("active=1").Find(&users)
+	res := s.db.Where("active=1").Find(&users)
 	if res.Error != nil {
 		return users, res.Error
 	}
@@ -260,15 +517,22 @@ func GetActiveUsers() ([]User, error) {
 	return users, nil
 }
 
-func GetUser(s string) (user User, err error) {
-	res := statsDB.Where("name = ? OR discord_id = ?", s, s).First(&user)
+func (s *Store) GetUser(name string) (user User, err error) {
+	res := s.db.Where("name = ? OR discord_id = ?", name, name).First(&user)
+
+	return user, res.Error
+}
+
+// GetUserByDiscordID returns the user with the discord ID, names are never matched
+func (s *Store) GetUserByDiscordID(discordID string) (user User, err error) {
+	res := s.db.Where("discord_id = ?", discordID).First(&user)
 
 	return user, res.Error
 }
 
-func CheckAdmin(s string) bool {
+func (s *Store) CheckAdmin(discordID string) bool {
 	var user User
-	_ = statsDB.Where("discord_id = ?", s).First(&user)
+	_ = s.db.Where("discord_id = ?", discordID).First(&user)
 
 	return user.Admin
 }
@@ -277,16 +541,89 @@ func (Stat) TableName() string {
 	return "Stat"
 }
 
-func NewStat(c Category, u User, v int) error {
+func (StatHistory) TableName() string {
+	return "StatHistory"
+}
+
+// GetHistory returns the user's submissions newest first, optionally only for one category
+func (s *Store) GetHistory(u User, category string, limit, offset int) (history []StatHistory, total int, err error) {
+	q := s.db.Model(&StatHistory{}).Where("user_id = ?", u.ID)
+	if category != "" {
+		c, err := s.GetCategory(category)
+		if err != nil {
+			return nil, 0, err
+		}
+		q = q.Where("category_id = ?", c.ID)
+	}
+
+	res := q.Count(&total)
+	if res.Error != nil {
+		return nil, 0, res.Error
+	}
+
+	res = q.Preload("Category").Order("created_at desc, id desc").Limit(limit).Offset(offset).Find(&history)
+	return history, total, res.Error
+}
+
+// DeleteStat removes the user's stat for the category along with its history,
+// so the value can't be brought back by an undo
+func (s *Store) DeleteStat(c Category, u User) error {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	err := s.withDB(tx).deleteStat(c, u)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (s *Store) deleteStat(c Category, u User) error {
+	res := s.db.Unscoped().Where("category_id = ? AND user_id = ?", c.ID, u.ID).Delete(&Stat{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return s.db.Where("category_id = ? AND user_id = ?", c.ID, u.ID).Delete(&StatHistory{}).Error
+}
+
+// NewStat sets the user's value without validating it or recording who submitted it
+func (s *Store) NewStat(c Category, u User, v int) error {
+	return s.saveStat(c, u, v, false, "")
+}
+
+// saveStat sets the user's value and adds it to their history, by is the discord ID of whoever sent it
+func (s *Store) saveStat(c Category, u User, v int, correction bool, by string) error {
+	var old *int
+	if s.onSave != nil && !correction {
+		if prev, err := s.GetStat(c, u); err == nil {
+			old = &prev.Value
+		}
+	}
+
 	stat := Stat{
 		Category: c,
 		User:     u,
 	}
-	fmt.Println(stat)
-	res := statsDB.Where(Stat{CategoryID: c.ID, UserID: u.ID}).Assign(Stat{Value: v}).FirstOrCreate(&stat)
+	// a map rather than a Stat, gorm skips zero fields of a struct and 0 is a valid value
+	res := s.db.Where(Stat{CategoryID: c.ID, UserID: u.ID}).Assign(map[string]interface{}{"value": v}).FirstOrCreate(&stat)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	res = s.db.Create(&StatHistory{CategoryID: c.ID, UserID: u.ID, Value: v, Correction: correction, SubmittedBy: by})
 	if res.Error != nil {
-		log.Printf("Error create new stat: %+v\n", res.Error)
 		return res.Error
 	}
+
+	s.metrics.submissions.WithLabelValues(c.Name).Inc()
+	if s.onSave != nil && !correction {
+		s.onSave(StatChange{Category: c, User: u, Old: old, Value: v})
+	}
 	return nil
 }
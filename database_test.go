@@ -0,0 +1,99 @@
+package statsbot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestBetterDirection runs every ranking path on a category ranked highest
+// first and on one ranked lowest first. alice has 30 and bob 50, then alice
+// drops to 20 and bob climbs to 60 during an event.
+func TestBetterDirection(t *testing.T) {
+	for _, tc := range []struct {
+		lower   bool
+		best    string
+		rank    string
+		team    string
+		gainer  string
+		outlier int
+	}{
+		{lower: false, best: "bob", rank: "7/7", team: "mystic", gainer: "bob", outlier: 100000},
+		{lower: true, best: "alice", rank: "1/7", team: "valor", gainer: "alice", outlier: 1},
+	} {
+		t.Run(fmt.Sprintf("lower=%v", tc.lower), func(t *testing.T) {
+			s := newTestStore(t)
+			c := Category{Name: "speedrun", FullName: "Speedrun", Max: 100000, LowerIsBetter: tc.lower}
+			if err := s.AddCategory(&c); err != nil {
+				t.Fatal(err)
+			}
+
+			alice := User{DiscordID: "1", Name: "alice", Team: "valor"}
+			bob := User{DiscordID: "2", Name: "bob", Team: "mystic"}
+			for _, u := range []*User{&alice, &bob} {
+				if err := s.InsertUser(u); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.NewStat(c, alice, 30)
+			s.NewStat(c, bob, 50)
+			for i := 3; i < 8; i++ {
+				u := User{DiscordID: fmt.Sprint(i), Name: fmt.Sprint("user", i)}
+				if err := s.InsertUser(&u); err != nil {
+					t.Fatal(err)
+				}
+				s.NewStat(c, u, 38+i)
+			}
+
+			stats, err := s.GetAll(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats[0].User.Name != tc.best {
+				t.Errorf("the board starts with %s, want %s", stats[0].User.Name, tc.best)
+			}
+			if rank, _ := s.GetUserRank(c, alice.ID); rank != tc.rank {
+				t.Errorf("alice ranks %s, want %s", rank, tc.rank)
+			}
+
+			teams, err := s.TeamStats(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(teams) != 2 || teams[0].Team != tc.team {
+				t.Errorf("team board is %+v, want %s first", teams, tc.team)
+			}
+
+			e := Event{Name: "Race", Category: c, CategoryID: c.ID, StartsAt: time.Now(), EndsAt: time.Now().Add(time.Hour)}
+			if err := s.AddEvent(&e); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.StartEvent(&e); err != nil {
+				t.Fatal(err)
+			}
+			s.NewStat(c, alice, 20)
+			s.NewStat(c, bob, 60)
+			standings, err := s.EventStandings(e, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(standings) != 2 || standings[0].User.Name != tc.gainer || standings[0].Gain != 10 {
+				t.Errorf("event standings are %+v, want %s to gain 10", standings, tc.gainer)
+			}
+
+			carol := User{DiscordID: "8", Name: "carol"}
+			if err := s.InsertUser(&carol); err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range []int{1, 100000} {
+				reason, err := s.Outlier(c, carol, v, 2, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (reason != "") != (v == tc.outlier) {
+					t.Errorf("Outlier(%d) = %q", v, reason)
+				}
+			}
+		})
+	}
+}
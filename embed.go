@@ -18,12 +18,22 @@ const (
 	EmbedLimitField       = 25
 	EmbedLimitFooter      = 2048
 	EmbedLimit            = 4000
+
+	MessageLimit = 2000
 )
 
 func Example(s string) string {
 	return fmt.Sprintf("```css\n%s\n```", s)
 }
 
+// TruncateMessage shortens s to fit in a single discord message
+func TruncateMessage(s string) string {
+	if len(s) > MessageLimit {
+		s = s[:MessageLimit-3] + "..."
+	}
+	return s
+}
+
 func NewEmbed() *Embed {
 	return &Embed{&discordgo.MessageEmbed{}}
 }
@@ -0,0 +1,481 @@
+package statsbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+)
+
+// eventInterval is how often the bot checks for events that started or ended
+const eventInterval = time.Minute
+
+// eventTimeLayout is how event times are written, dates alone use dateLayout
+const eventTimeLayout = "2006-01-02T15:04"
+
+var ERR_EVENT_NOT_FOUND = errors.New("Event not found.")
+
+// Event is a competition for the biggest gain in a category between StartsAt and EndsAt
+type Event struct {
+	ID         int `gorm:"primary_key"`
+	CreatedAt  time.Time
+	Name       string `gorm:"size:64"`
+	Category   Category
+	CategoryID int
+	StartsAt   time.Time `gorm:"index"`
+	EndsAt     time.Time `gorm:"index"`
+
+	// ChannelID is where the event was created, the final standings are posted there
+	ChannelID string `gorm:"size:20"`
+
+	// Started is set once the participants' starting values are recorded, Finished once the results are posted
+	Started  bool `gorm:"DEFAULT:false"`
+	Finished bool `gorm:"DEFAULT:false"`
+}
+
+func (Event) TableName() string {
+	return "Event"
+}
+
+// EventSnapshot is a user's value when the event started
+type EventSnapshot struct {
+	ID      int `gorm:"primary_key"`
+	EventID int `gorm:"unique_index:event_user"`
+	UserID  int `gorm:"unique_index:event_user"`
+	Value   int `gorm:"type:bigint"`
+}
+
+func (EventSnapshot) TableName() string {
+	return "EventSnapshot"
+}
+
+// EventStanding is a participant's progress in an event
+type EventStanding struct {
+	User  User
+	Start int
+	Value int
+	Gain  int
+}
+
+// AddEvent creates a new event
+func (s *Store) AddEvent(e *Event) error {
+	return s.db.Set("gorm:save_associations", false).Create(e).Error
+}
+
+// GetEvent returns the event with the ID
+func (s *Store) GetEvent(id int) (e Event, err error) {
+	res := s.db.Preload("Category").First(&e, id)
+	if res.Error == gorm.ErrRecordNotFound {
+		return e, ERR_EVENT_NOT_FOUND
+	}
+	return e, res.Error
+}
+
+// GetOpenEvents returns the events whose results haven't been posted, soonest ending first
+func (s *Store) GetOpenEvents() ([]Event, error) {
+	var events []Event
+	res := s.db.Preload("Category").Where("finished = ?", false).Order("ends_at asc, id asc").Find(&events)
+	return events, res.Error
+}
+
+// DeleteEvent deletes the event and its snapshots
+func (s *Store) DeleteEvent(e Event) error {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	res := tx.Where("event_id = ?", e.ID).Delete(&EventSnapshot{})
+	if res.Error == nil {
+		res = tx.Delete(&e)
+	}
+	if res.Error != nil {
+		tx.Rollback()
+		return res.Error
+	}
+	return tx.Commit().Error
+}
+
+// StartEvent records every user's value at the start of the event, it does nothing if they are already recorded
+func (s *Store) StartEvent(e *Event) error {
+	if e.Started {
+		return nil
+	}
+
+	// the latest value at the start, from history where there is one and from the stat otherwise
+	values := map[int]int{}
+	var stats []Stat
+	res := s.db.Where("category_id = ? AND updated_at <= ?", e.CategoryID, e.StartsAt).Find(&stats)
+	if res.Error != nil {
+		return res.Error
+	}
+	for _, stat := range stats {
+		values[stat.UserID] = stat.Value
+	}
+	var history []StatHistory
+	res = s.db.Where("category_id = ? AND created_at <= ?", e.CategoryID, e.StartsAt).Order("created_at asc, id asc").Find(&history)
+	if res.Error != nil {
+		return res.Error
+	}
+	for _, h := range history {
+		values[h.UserID] = h.Value
+	}
+
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// only one of the event ticker and !stats event can start it
+	res = tx.Model(&Event{}).Where("id = ? AND started = ?", e.ID, false).Update("started", true)
+	if res.Error != nil {
+		tx.Rollback()
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		e.Started = true
+		return nil
+	}
+
+	for userID, value := range values {
+		res = tx.Create(&EventSnapshot{EventID: e.ID, UserID: userID, Value: value})
+		if res.Error != nil {
+			tx.Rollback()
+			return res.Error
+		}
+	}
+	err := tx.Commit().Error
+	if err == nil {
+		e.Started = true
+	}
+	return err
+}
+
+// FinishEvent marks the event's results as posted
+func (s *Store) FinishEvent(e *Event) error {
+	e.Finished = true
+	return s.db.Model(&Event{}).Where("id = ?", e.ID).Update("finished", true).Error
+}
+
+// EventStandings returns the gains of everyone who submitted a value during the
+// event up to now, best first. Users who weren't recorded at the start are
+// measured from their first value in the event.
+func (s *Store) EventStandings(e Event, now time.Time) ([]EventStanding, error) {
+	end := e.EndsAt
+	if now.Before(end) {
+		end = now
+	}
+
+	var snapshots []EventSnapshot
+	res := s.db.Where("event_id = ?", e.ID).Find(&snapshots)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	start := map[int]int{}
+	for _, snap := range snapshots {
+		start[snap.UserID] = snap.Value
+	}
+
+	var history []StatHistory
+	res = s.db.Preload("User").Where("category_id = ? AND created_at > ? AND created_at <= ?", e.CategoryID, e.StartsAt, end).Order("created_at asc, id asc").Find(&history)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	byUser := map[int]*EventStanding{}
+	order := []int{}
+	for _, h := range history {
+		standing, ok := byUser[h.UserID]
+		if !ok {
+			first, recorded := start[h.UserID]
+			if !recorded {
+				first = h.Value
+			}
+			standing = &EventStanding{User: h.User, Start: first}
+			byUser[h.UserID] = standing
+			order = append(order, h.UserID)
+		}
+		standing.Value = h.Value
+	}
+
+	standings := []EventStanding{}
+	for _, id := range order {
+		standing := byUser[id]
+		standing.Gain = e.Category.Gain(standing.Start, standing.Value)
+		standings = append(standings, *standing)
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Gain > standings[j].Gain
+	})
+	return standings, nil
+}
+
+// FormatStandings writes the event's header and leaderboard
+func (e Event) FormatStandings(standings []EventStanding, now time.Time) string {
+	c := e.Category
+	message := fmt.Sprintf("**%s** (%s) #%d, %s to %s", e.Name, c.FullName, e.ID, e.StartsAt.Format(eventTimeLayout), e.EndsAt.Format(eventTimeLayout))
+	switch {
+	case now.Before(e.StartsAt):
+		message += fmt.Sprintf(", starts in %s\n", formatDuration(e.StartsAt.Sub(now)))
+		return message
+	case now.Before(e.EndsAt):
+		message += fmt.Sprintf(", ends in %s\n", formatDuration(e.EndsAt.Sub(now)))
+	default:
+		message += ", final standings\n"
+	}
+
+	if len(standings) == 0 {
+		return message + "No progress yet."
+	}
+
+	for i, standing := range standings {
+		change, sign := standing.Value-standing.Start, "+"
+		if change < 0 {
+			change, sign = -change, "-"
+		}
+		message += fmt.Sprintf("%d. %s %s%s (%s → %s)\n", i+1, standing.User.Name, sign, c.FormatValue(change), c.FormatValue(standing.Start), c.FormatValue(standing.Value))
+	}
+	return message
+}
+
+// formatDuration writes d in days, hours and minutes
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// parseEventTime reads now, a date or a date and time in the server's time zone.
+// A date alone as the end of an event means the end of that day.
+func parseEventTime(s string, now time.Time, end bool) (time.Time, error) {
+	if strings.EqualFold(s, "now") {
+		return now, nil
+	}
+	if t, err := time.ParseInLocation(eventTimeLayout, s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(dateLayout, s, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("%s is not a date, use %s or %s.", s, dateLayout, eventTimeLayout)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// splitQuoted splits s on spaces, keeping text between double quotes together
+func splitQuoted(s string) []string {
+	s = strings.NewReplacer("“", `"`, "”", `"`).Replace(s)
+
+	args := []string{}
+	var current strings.Builder
+	quoted, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted, started = !quoted, true
+		case r == ' ' && !quoted:
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// Event runs the !stats event commands, args is everything after "event"
+func (b *Bot) Event(ctx context.Context, m *discordgo.MessageCreate, args string) (string, error) {
+	fields := splitQuoted(args)
+	now := time.Now()
+
+	if len(fields) == 0 || fields[0] == "list" {
+		events, err := b.store.GetOpenEvents()
+		if err != nil {
+			return "", err
+		}
+		if len(events) == 0 {
+			return "There are no events.", nil
+		}
+		lines := []string{}
+		for _, e := range events {
+			lines = append(lines, fmt.Sprintf("#%d %s (%s), %s to %s", e.ID, e.Name, e.Category.FullName, e.StartsAt.Format(eventTimeLayout), e.EndsAt.Format(eventTimeLayout)))
+		}
+		return strings.Join(lines, "\n") + "\nUse `!stats event {id}` to see the standings.", nil
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "create":
+		if !b.store.CheckAdmin(m.Author.ID) {
+			return "", ERR_NOT_ADMIN
+		}
+		return b.createEvent(ctx, m, fields[1:], now)
+	case "cancel":
+		if !b.store.CheckAdmin(m.Author.ID) {
+			return "", ERR_NOT_ADMIN
+		}
+		if len(fields) != 2 {
+			return "", errors.New("Use `!stats event cancel {id}`.")
+		}
+		e, err := b.eventByID(fields[1])
+		if err != nil {
+			return "", err
+		}
+		err = b.store.DeleteEvent(e)
+		if err != nil {
+			return "", err
+		}
+		b.audit(ctx, m, AuditEntry{Action: "cancel event", Category: e.Category.Name, OldValue: e.Name})
+		return fmt.Sprintf("Cancelled %s.", e.Name), nil
+	}
+
+	e, err := b.eventByID(fields[0])
+	if err != nil {
+		return "", err
+	}
+	if !e.Started && !now.Before(e.StartsAt) {
+		err = b.store.StartEvent(&e)
+		if err != nil {
+			return "", err
+		}
+	}
+	standings, err := b.store.EventStandings(e, now)
+	if err != nil {
+		return "", err
+	}
+	return e.FormatStandings(standings, now), nil
+}
+
+// eventByID returns the event with the ID written as n or #n
+func (b *Bot) eventByID(id string) (Event, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, "#"))
+	if err != nil {
+		return Event{}, ERR_EVENT_NOT_FOUND
+	}
+	return b.store.GetEvent(n)
+}
+
+func (b *Bot) createEvent(ctx context.Context, m *discordgo.MessageCreate, args []string, now time.Time) (string, error) {
+	if len(args) != 4 {
+		return "", errors.New("Use `!stats event create \"name\" {category} {start} {end}`, times are now, 2006-01-02 or 2006-01-02T15:04.")
+	}
+
+	name := strings.TrimSpace(args[0])
+	if name == "" || len([]rune(name)) > 64 {
+		return "", errors.New("Event names must be 1 to 64 characters.")
+	}
+	category, err := b.store.GetCategory(strings.ToLower(args[1]))
+	if err != nil {
+		return "", err
+	}
+	start, err := parseEventTime(args[2], now, false)
+	if err != nil {
+		return "", err
+	}
+	end, err := parseEventTime(args[3], now, true)
+	if err != nil {
+		return "", err
+	}
+	if !end.After(start) || !end.After(now) {
+		return "", errors.New("The event has to end in the future, after it starts.")
+	}
+
+	e := Event{Name: name, Category: category, CategoryID: category.ID, StartsAt: start, EndsAt: end, ChannelID: m.ChannelID}
+	err = b.store.AddEvent(&e)
+	if err != nil {
+		return "", err
+	}
+	if !now.Before(start) {
+		err = b.store.StartEvent(&e)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	b.audit(ctx, m, AuditEntry{Action: "create event", Category: category.Name, NewValue: name})
+	return fmt.Sprintf("Created event #%d %s for %s, %s to %s.", e.ID, e.Name, category.FullName, start.Format(eventTimeLayout), end.Format(eventTimeLayout)), nil
+}
+
+// CheckEvents records the starting values of events that began and posts the final standings of events that ended
+func (b *Bot) CheckEvents(now time.Time) {
+	events, err := b.store.GetOpenEvents()
+	if err != nil {
+		slog.Error("Unable to get events", "error", err)
+		return
+	}
+
+	for _, e := range events {
+		e := e
+		log := slog.With("event", e.ID)
+
+		if !e.Started && !now.Before(e.StartsAt) {
+			if err := b.store.StartEvent(&e); err != nil {
+				log.Error("Unable to start event", "error", err)
+				continue
+			}
+			log.Info("Event started")
+		}
+
+		if now.Before(e.EndsAt) {
+			continue
+		}
+		standings, err := b.store.EventStandings(e, now)
+		if err != nil {
+			log.Error("Unable to get event standings", "error", err)
+			continue
+		}
+		if e.ChannelID != "" {
+			_, err = b.session.ChannelMessageSend(e.ChannelID, TruncateMessage(e.FormatStandings(standings, now)))
+			if err != nil {
+				log.Warn("Unable to post event results", "error", err)
+				continue
+			}
+		}
+		if err := b.store.FinishEvent(&e); err != nil {
+			log.Error("Unable to finish event", "error", err)
+			continue
+		}
+		log.Info("Event finished", "participants", len(standings))
+	}
+}
+
+// runEvents checks the events every eventInterval until ctx is done or the bot starts draining
+func (b *Bot) runEvents(ctx context.Context) {
+	ticker := time.NewTicker(eventInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !b.begin() {
+				return
+			}
+			b.CheckEvents(now)
+			b.inflight.Done()
+		}
+	}
+}
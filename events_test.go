@@ -0,0 +1,46 @@
+package statsbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventStartsOnce(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add collector 100")
+	send(b, f, "2", "!stats add collector 200")
+
+	c, _ := b.store.GetCategory("collector")
+	now := time.Now()
+	e := Event{Name: "Spring", Category: c, CategoryID: c.ID, StartsAt: now.Add(time.Minute), EndsAt: now.Add(time.Hour)}
+	if err := b.store.AddEvent(&e); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ticker and !stats event both loaded the event before either started it
+	ticker, command := e, e
+	if err := b.store.StartEvent(&ticker); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.store.StartEvent(&command); err != nil || !command.Started {
+		t.Fatalf("starting an event twice got %v", err)
+	}
+	var snapshots int
+	b.store.db.Model(&EventSnapshot{}).Where("event_id = ?", e.ID).Count(&snapshots)
+	if snapshots != 2 {
+		t.Errorf("got %d snapshots, want 2", snapshots)
+	}
+
+	e.StartsAt = now.Add(-time.Minute)
+	b.store.db.Model(&e).UpdateColumn("starts_at", e.StartsAt)
+	send(b, f, "1", "!stats add collector 150")
+	send(b, f, "2", "!stats add collector 220")
+
+	standings, err := b.store.EventStandings(e, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(standings) != 2 || standings[0].User.Name != "alice" || standings[0].Gain != 50 || standings[1].Gain != 20 {
+		t.Errorf("got standings %+v", standings)
+	}
+}
@@ -0,0 +1,217 @@
+package statsbot
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var ERR_CATEGORY_NOT_FOUND = errors.New("No such category, use `!stats categories` to see them.")
+
+// ExportFormats are the file formats !stats export can produce
+var ExportFormats = []string{"csv", "json", "xlsx"}
+
+func isExportFormat(s string) bool {
+	for _, f := range ExportFormats {
+		if s == f {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportRow is one user's stat in an export
+type ExportRow struct {
+	Category  string    `json:"category"`
+	Rank      int       `json:"rank"`
+	User      string    `json:"user"`
+	DiscordID string    `json:"discord_id"`
+	Value     float64   `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Active    bool      `json:"active"`
+
+	// Value is in the category's displayed units and Display is it formatted for the category
+	Display string `json:"display"`
+	Tier    string `json:"tier"`
+	Team    string `json:"team"`
+}
+
+var exportHeader = []string{"category", "rank", "user", "discord_id", "value", "updated_at", "active", "display", "tier", "team"}
+
+func (r ExportRow) fields() []string {
+	return []string{
+		r.Category,
+		strconv.Itoa(r.Rank),
+		r.User,
+		r.DiscordID,
+		strconv.FormatFloat(r.Value, 'f', -1, 64),
+		r.UpdatedAt.UTC().Format(time.RFC3339),
+		strconv.FormatBool(r.Active),
+		r.Display,
+		r.Tier,
+		r.Team,
+	}
+}
+
+// ExportRows returns the leaderboard for the category, or for every category when name is "all"
+func (s *Store) ExportRows(name string) ([]ExportRow, error) {
+	var categories []Category
+	if name == "" || name == "all" {
+		var err error
+		categories, err = s.GetCategories()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		category, err := s.GetCategory(name)
+		if err == gorm.ErrRecordNotFound {
+			return nil, ERR_CATEGORY_NOT_FOUND
+		} else if err != nil {
+			return nil, err
+		}
+		categories = []Category{category}
+	}
+
+	rows := []ExportRow{}
+	for _, category := range categories {
+		stats, err := s.GetAll(category)
+		if err != nil {
+			return nil, err
+		}
+		for i, stat := range stats {
+			rows = append(rows, ExportRow{
+				Category:  category.Name,
+				Rank:      i + 1,
+				User:      stat.User.Name,
+				DiscordID: stat.User.DiscordID,
+				Value:     category.Number(stat.Value),
+				UpdatedAt: stat.UpdatedAt,
+				Active:    stat.User.Active,
+				Display:   category.FormatValue(stat.Value),
+				Tier:      category.Tier(stat.Value).Name,
+				Team:      stat.User.Team,
+			})
+		}
+	}
+	return rows, nil
+}
+
+// WriteExport writes rows to w in the given format
+func WriteExport(w io.Writer, format string, rows []ExportRow) error {
+	switch format {
+	case "csv":
+		return writeExportCSV(w, rows)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "xlsx":
+		return writeExportXLSX(w, rows)
+	}
+	return fmt.Errorf("Unknown format %s, use one of: %s", format, strings.Join(ExportFormats, ", "))
+}
+
+func writeExportCSV(w io.Writer, rows []ExportRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := cw.Write(row.fields()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeExportXLSX writes a single sheet workbook holding the rows
+func writeExportXLSX(w io.Writer, rows []ExportRow) error {
+	var sheet bytes.Buffer
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	writeXLSXRow(&sheet, 1, exportHeader, nil)
+	for i, row := range rows {
+		writeXLSXRow(&sheet, i+2, row.fields(), map[int]bool{1: true, 4: true})
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	files := []struct {
+		name string
+		body string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Stats" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, file.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeXLSXRow writes one sheet row, the columns in numeric are written as numbers
+func writeXLSXRow(w *bytes.Buffer, row int, cells []string, numeric map[int]bool) {
+	fmt.Fprintf(w, `<row r="%d">`, row)
+	for i, cell := range cells {
+		ref := fmt.Sprintf("%c%d", 'A'+i, row)
+		if numeric[i] {
+			fmt.Fprintf(w, `<c r="%s"><v>%s</v></c>`, ref, cell)
+			continue
+		}
+		fmt.Fprintf(w, `<c r="%s" t="inlineStr"><is><t>`, ref)
+		xml.EscapeText(w, []byte(cell))
+		w.WriteString(`</t></is></c>`)
+	}
+	w.WriteString(`</row>`)
+}
+
+// Export builds the export file for the category in the given format
+func (b *Bot) Export(category, format string) (name string, data []byte, err error) {
+	rows, err := b.store.ExportRows(category)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var buf bytes.Buffer
+	err = WriteExport(&buf, format, rows)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if category == "" {
+		category = "all"
+	}
+	name = fmt.Sprintf("stats-%s-%s.%s", category, time.Now().Format("2006-01-02"), format)
+	return name, buf.Bytes(), nil
+}
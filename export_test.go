@@ -0,0 +1,78 @@
+package statsbot
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExportDecimalValues(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add jogger 12.5")
+
+	rows, err := b.store.ExportRows("jogger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Value != 12.5 || rows[0].Display != "12.5 km" {
+		t.Fatalf("got rows %+v", rows)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteExport(&buf, "csv", rows); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1][4] != "12.5" {
+		t.Errorf("got csv %q", records)
+	}
+}
+
+func TestExportXLSX(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add jogger 12.5")
+	send(b, f, "2", "!stats add jogger 3")
+
+	_, data, err := b.Export("jogger", "xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sheet string
+	for _, file := range zr.File {
+		if file.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		sheet = string(body)
+	}
+
+	for _, cell := range []string{`<c r="E2"><v>12.5</v></c>`, `<c r="E3"><v>3</v></c>`, `<c r="B2"><v>1</v></c>`, `<t>alice</t>`} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("sheet is missing %s:\n%s", cell, sheet)
+		}
+	}
+}
+
+func TestExportUnknownCategory(t *testing.T) {
+	b, f := newTestBot(t, nil)
+
+	if got := send(b, f, "1", "!stats export bogus"); len(got) != 1 || got[0] != ERR_CATEGORY_NOT_FOUND.Error() {
+		t.Errorf("export of an unknown category replied %q", got)
+	}
+}
@@ -0,0 +1,210 @@
+package statsbot
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var ERR_NOT_FOUND = errors.New("Not found.")
+
+// FakeMessage is a message sent through a FakeSession
+type FakeMessage struct {
+	ChannelID string
+	Content   string
+	Embed     *discordgo.MessageEmbed
+	FileName  string
+	File      []byte
+	Files     []*discordgo.File
+
+	// Components are the buttons sent with the message
+	Components []discordgo.MessageComponent
+
+	// AllowedMentions limits who the message pings, nil pings everyone mentioned
+	AllowedMentions *discordgo.MessageAllowedMentions
+}
+
+// FakeSession is an in-memory Session that records every message sent through it
+type FakeSession struct {
+	Users    map[string]*discordgo.User
+	Channels map[string]*discordgo.Channel
+	Guilds   map[string]*discordgo.Guild
+
+	mu       sync.Mutex
+	messages []FakeMessage
+}
+
+// NewFakeSession creates an empty FakeSession
+func NewFakeSession() *FakeSession {
+	return &FakeSession{
+		Users:    map[string]*discordgo.User{},
+		Channels: map[string]*discordgo.Channel{},
+		Guilds:   map[string]*discordgo.Guild{},
+	}
+}
+
+// AddMember adds a user to the guild, creating the guild if needed
+func (f *FakeSession) AddMember(guildID string, user *discordgo.User, nick string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Users[user.ID] = user
+	guild, ok := f.Guilds[guildID]
+	if !ok {
+		guild = &discordgo.Guild{ID: guildID}
+		f.Guilds[guildID] = guild
+	}
+	guild.Members = append(guild.Members, &discordgo.Member{GuildID: guildID, User: user, Nick: nick})
+}
+
+// AddChannel adds a text channel to the guild
+func (f *FakeSession) AddChannel(guildID, channelID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Channels[channelID] = &discordgo.Channel{ID: channelID, GuildID: guildID}
+}
+
+// Messages returns the messages sent so far
+func (f *FakeSession) Messages() []FakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeMessage(nil), f.messages...)
+}
+
+// Reset forgets the messages sent so far
+func (f *FakeSession) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.messages = nil
+}
+
+func (f *FakeSession) send(msg FakeMessage) *discordgo.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.messages = append(f.messages, msg)
+	m := &discordgo.Message{
+		ID:        strconv.Itoa(len(f.messages)),
+		ChannelID: msg.ChannelID,
+		Content:   msg.Content,
+	}
+	if msg.Embed != nil {
+		m.Embeds = []*discordgo.MessageEmbed{msg.Embed}
+	}
+	return m
+}
+
+func (f *FakeSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return f.send(FakeMessage{ChannelID: channelID, Content: content}), nil
+}
+
+func (f *FakeSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return f.send(FakeMessage{ChannelID: channelID, Embed: embed}), nil
+}
+
+func (f *FakeSession) ChannelFileSend(channelID, name string, r io.Reader) (*discordgo.Message, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return f.send(FakeMessage{ChannelID: channelID, FileName: name, File: data}), nil
+}
+
+func (f *FakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	return f.send(FakeMessage{ChannelID: channelID, Content: data.Content, Embed: data.Embed, Files: data.Files, Components: data.Components, AllowedMentions: data.AllowedMentions}), nil
+}
+
+// InteractionRespond records the response as a message in the interaction's channel
+func (f *FakeSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error {
+	msg := FakeMessage{ChannelID: interaction.ChannelID}
+	if resp.Data != nil {
+		msg.Content, msg.Components = resp.Data.Content, resp.Data.Components
+	}
+	f.send(msg)
+	return nil
+}
+
+func (f *FakeSession) User(userID string) (*discordgo.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if u, ok := f.Users[userID]; ok {
+		return u, nil
+	}
+	return nil, ERR_NOT_FOUND
+}
+
+func (f *FakeSession) Channel(channelID string) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.Channels[channelID]; ok {
+		return c, nil
+	}
+	return nil, ERR_NOT_FOUND
+}
+
+func (f *FakeSession) Guild(guildID string) (*discordgo.Guild, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if g, ok := f.Guilds[guildID]; ok {
+		return g, nil
+	}
+	return nil, ERR_NOT_FOUND
+}
+
+func (f *FakeSession) GuildMembers(guildID string, after string, limit int) ([]*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, ok := f.Guilds[guildID]
+	if !ok {
+		return nil, ERR_NOT_FOUND
+	}
+
+	members := []*discordgo.Member{}
+	found := after == ""
+	for _, mem := range g.Members {
+		if !found {
+			found = mem.User.ID == after
+			continue
+		}
+		if limit > 0 && len(members) == limit {
+			break
+		}
+		members = append(members, mem)
+	}
+	return members, nil
+}
+
+// GuildMembersSearch returns the members whose username or nickname starts with query, ignoring case like discord
+func (f *FakeSession) GuildMembersSearch(guildID, query string, limit int) ([]*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, ok := f.Guilds[guildID]
+	if !ok {
+		return nil, ERR_NOT_FOUND
+	}
+
+	query = strings.ToLower(query)
+	members := []*discordgo.Member{}
+	for _, mem := range g.Members {
+		if limit > 0 && len(members) == limit {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(mem.User.Username), query) || strings.HasPrefix(strings.ToLower(mem.Nick), query) {
+			members = append(members, mem)
+		}
+	}
+	return members, nil
+}
@@ -0,0 +1,181 @@
+package statsbot
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+)
+
+// maxImageSize is the largest custom category image that can be uploaded
+const maxImageSize = 1 << 20
+
+//go:embed img/*.png
+var medalImages embed.FS
+
+var (
+	ERR_NO_IMAGE      = errors.New("No image for this category.")
+	ERR_INVALID_IMAGE = errors.New("Images must be PNG, JPEG or GIF files under 1MB.")
+)
+
+// CategoryImage is a custom image uploaded for a category, it replaces the built in medal
+type CategoryImage struct {
+	CategoryID  int `gorm:"primary_key;auto_increment:false"`
+	ContentType string
+	Data        []byte `gorm:"type:mediumblob"`
+	UpdatedAt   time.Time
+}
+
+func (CategoryImage) TableName() string {
+	return "CategoryImage"
+}
+
+// SetCategoryImage stores a custom image for the category and clears any image URL set on it
+func (s *Store) SetCategoryImage(c Category, data []byte) error {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return ERR_INVALID_IMAGE
+	}
+	if len(data) > maxImageSize {
+		return ERR_INVALID_IMAGE
+	}
+
+	img := CategoryImage{CategoryID: c.ID, ContentType: contentType, Data: data}
+	res := s.db.Save(&img)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	return s.db.Model(&c).Update("image", "").Error
+}
+
+// MedalImage returns the category's uploaded image, or the built in medal if there is none
+func (s *Store) MedalImage(c Category) (contentType string, data []byte, err error) {
+	var img CategoryImage
+	res := s.db.Where("category_id = ?", c.ID).First(&img)
+	if res.Error == nil {
+		return img.ContentType, img.Data, nil
+	} else if res.Error != gorm.ErrRecordNotFound {
+		return "", nil, res.Error
+	}
+
+	data, err = medalImages.ReadFile("img/" + c.Name + ".png")
+	if err != nil {
+		return "", nil, ERR_NO_IMAGE
+	}
+	return "image/png", data, nil
+}
+
+// HasMedalImage reports whether MedalImage has an image for the category
+func (s *Store) HasMedalImage(c Category) bool {
+	if _, err := fs.Stat(medalImages, "img/"+c.Name+".png"); err == nil {
+		return true
+	}
+
+	count := 0
+	s.db.Model(&CategoryImage{}).Where("category_id = ?", c.ID).Count(&count)
+	return count > 0
+}
+
+// imageExt returns the file extension for an image content type
+func imageExt(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".png"
+}
+
+// categoryIcon returns the icon URL to put in a category embed. Without a
+// public URL for the bot's HTTP server the image is returned as a file to
+// attach to the message and the URL points at the attachment.
+func (b *Bot) categoryIcon(c Category) (url string, file *discordgo.File) {
+	if c.Image != "" {
+		return c.Image, nil
+	}
+
+	if b.config.PublicURL != "" {
+		if !b.store.HasMedalImage(c) {
+			return "", nil
+		}
+		return strings.TrimRight(b.config.PublicURL, "/") + "/img/" + c.Name, nil
+	}
+
+	contentType, data, err := b.store.MedalImage(c)
+	if err != nil {
+		return "", nil
+	}
+
+	name := c.Name + imageExt(contentType)
+	return "attachment://" + name, &discordgo.File{Name: name, ContentType: contentType, Reader: bytes.NewReader(data)}
+}
+
+// sendCategoryEmbed sends an embed with the category as its author, attaching the category icon if needed
+func (b *Bot) sendCategoryEmbed(s Session, channelID string, c Category, e *Embed) (*discordgo.Message, error) {
+	icon, file := b.categoryIcon(c)
+	e.SetAuthor(c.FullName, icon)
+
+	if file == nil {
+		return s.ChannelMessageSendEmbed(channelID, e.MessageEmbed)
+	}
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embed: e.MessageEmbed,
+		Files: []*discordgo.File{file},
+	})
+}
+
+// UploadCategoryImage replaces the category's image with the one attached to the message
+func (b *Bot) UploadCategoryImage(ctx context.Context, m *discordgo.MessageCreate, category string) error {
+	if len(m.Attachments) == 0 {
+		return errors.New("Attach an image to upload.")
+	}
+
+	c, err := b.store.GetCategory(category)
+	if err != nil {
+		return err
+	}
+
+	body, err := downloadAttachment(ctx, m.Attachments[0].URL)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return b.store.SetCategoryImage(c, data)
+}
+
+// serveCategoryImage serves /img/{name}, where name is a category name or a built in medal file
+func (b *Bot) serveCategoryImage(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	c, err := b.store.GetCategory(strings.TrimSuffix(name, path.Ext(name)))
+	if err == nil {
+		contentType, data, err := b.store.MedalImage(c)
+		if err == nil {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			return
+		}
+	}
+
+	images, _ := fs.Sub(medalImages, "img")
+	http.StripPrefix("/img/", http.FileServer(http.FS(images))).ServeHTTP(w, r)
+}
@@ -0,0 +1,75 @@
+package statsbot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type loggerKey struct{}
+
+// NewLogger creates the logger described by the config's LogFormat and LogLevel
+func (c *Config) NewLogger(w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if c.LogLevel != "" {
+		err := level.UnmarshalText([]byte(c.LogLevel))
+		if err != nil {
+			return nil, fmt.Errorf("Unknown log level %s, use one of: debug, info, warn, error", c.LogLevel)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(c.LogFormat) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("Unknown log format %s, use one of: text, json", c.LogFormat)
+}
+
+// withLogger returns a context carrying the request scoped logger l
+func withLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// loggerFrom returns the request scoped logger from ctx, or the default logger
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// messageLogger returns a logger with the fields identifying the message m
+func messageLogger(m *discordgo.MessageCreate) *slog.Logger {
+	l := slog.Default().With("guild", m.GuildID, "channel", m.ChannelID, "message", m.ID)
+	if m.Author != nil {
+		l = l.With("user", m.Author.ID)
+	}
+	return l
+}
+
+// gormLogger sends gorm's output to the default logger, queries are only logged at debug level
+type gormLogger struct{}
+
+func (gormLogger) Print(v ...interface{}) {
+	if len(v) < 2 {
+		return
+	}
+
+	switch v[0] {
+	case "sql":
+		if len(v) >= 6 {
+			slog.Debug("Database query", "source", v[1], "duration", v[2], "query", v[3], "rows", v[5])
+		}
+	case "log":
+		slog.Warn("Database error", "source", v[1], "error", fmt.Sprint(v[2:]...))
+	default:
+		slog.Debug("Database", "message", fmt.Sprint(v[1:]...))
+	}
+}
@@ -0,0 +1,52 @@
+package statsbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestLogsCarryTheMessage(t *testing.T) {
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	b, _ := newTestBot(t, nil)
+	buf.Reset()
+	b.HandleMessage(&discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "m1",
+		GuildID:   "g1",
+		ChannelID: "c1",
+		Content:   "!stats add collector lots",
+		Author:    &discordgo.User{ID: "1", Username: "alice"},
+	}})
+
+	seen := map[string]bool{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		msg, _ := record["msg"].(string)
+		if msg == "Database query" {
+			continue
+		}
+		seen[msg] = true
+		for k, v := range map[string]string{"guild": "g1", "channel": "c1", "message": "m1", "user": "1"} {
+			if record[k] != v {
+				t.Errorf("%q logged %s as %v, want %s", msg, k, record[k], v)
+			}
+		}
+	}
+
+	for _, msg := range []string{"Handling command", "Invalid value", "Command failed"} {
+		if !seen[msg] {
+			t.Errorf("%q wasn't logged", msg)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package statsbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+)
+
+// forgetTimeout is how long a !stats me forget request waits for its confirmation
+const forgetTimeout = 5 * time.Minute
+
+var (
+	ERR_NOT_REGISTERED = errors.New("You haven't joined the stats program, use `!stats user` first.")
+	ERR_INVALID_NAME   = errors.New("Names must be 1 to 20 characters with no spaces, @, <, > or `, and can't be only digits.")
+	ERR_NAME_TAKEN     = errors.New("That name is already taken.")
+)
+
+// RenameUser changes the user's display name
+func (s *Store) RenameUser(u *User, name string) error {
+	if !validName(name) {
+		return ERR_INVALID_NAME
+	}
+
+	taken, err := s.nameTaken(u, name)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ERR_NAME_TAKEN
+	}
+
+	u.Name, u.CustomName = name, true
+	return s.db.Model(u).Updates(map[string]interface{}{"name": name, "custom_name": true}).Error
+}
+
+// validName reports whether users can pick name. Names are printed in plain
+// messages so they can't hold mentions or markdown code, and names made of
+// digits would pass for a discord ID.
+func validName(name string) bool {
+	if name == "" || utf8.RuneCountInString(name) > 20 || strings.ContainsAny(name, " \t@<>`") {
+		return false
+	}
+	return strings.Trim(name, "0123456789") != ""
+}
+
+// ForgetUser deletes the user with all of their stats and history, and removes them from the audit log
+func (s *Store) ForgetUser(u User) error {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	txs := s.withDB(tx)
+
+	err := txs.forgetUser(u)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (s *Store) forgetUser(u User) error {
+	res := s.db.Unscoped().Where("user_id = ?", u.ID).Delete(&Stat{})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	res = s.db.Where("user_id = ?", u.ID).Delete(&StatHistory{})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	res = s.db.Where("user_id = ?", u.ID).Delete(&PendingStat{})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	res = s.db.Where("user_id = ?", u.ID).Delete(&EventSnapshot{})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	res = s.db.Model(&AuditEntry{}).Where("actor_id = ?", u.DiscordID).Updates(map[string]interface{}{"actor_id": "", "actor": "deleted user"})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	res = s.db.Model(&AuditEntry{}).Where("target_id = ?", u.DiscordID).Updates(map[string]interface{}{"target_id": "", "target": "deleted user"})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	return s.db.Delete(&u).Error
+}
+
+// Me runs the !stats me commands for the author of m
+func (b *Bot) Me(m *discordgo.MessageCreate, args []string) (string, error) {
+	user, err := b.store.GetUserByDiscordID(m.Author.ID)
+	if err == gorm.ErrRecordNotFound {
+		return "", ERR_NOT_REGISTERED
+	} else if err != nil {
+		return "", err
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		status := "active"
+		if !user.Active {
+			status = "paused"
+		}
+		if user.NoAnnounce {
+			status += ", without announcements"
+		}
+		return fmt.Sprintf("You are %s and %s.\nUse `!stats me rename {name}`, `!stats me pause`, `!stats me resume`, `!stats me announce on|off` or `!stats me forget`.", user.Name, status), nil
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "rename":
+		if len(args) != 2 {
+			return "", errors.New("Use `!stats me rename {name}`.")
+		}
+		err = b.store.RenameUser(&user, args[1])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("You are now %s.", user.Name), nil
+	case "pause":
+		err = b.store.SetActive(&user, false)
+		if err != nil {
+			return "", err
+		}
+		return "Paused, you won't get reminders until you `!stats me resume`.", nil
+	case "resume":
+		err = b.store.SetActive(&user, true)
+		if err != nil {
+			return "", err
+		}
+		return "Welcome back!", nil
+	case "announce":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			return "", errors.New("Use `!stats me announce on` or `!stats me announce off`.")
+		}
+		err = b.store.SetAnnounce(&user, args[1] == "on")
+		if err != nil {
+			return "", err
+		}
+		if user.NoAnnounce {
+			return "Your milestones won't be announced anymore.", nil
+		}
+		return "Your milestones will be announced.", nil
+	case "forget":
+		if len(args) == 2 && strings.ToLower(args[1]) == "confirm" && b.confirmForget(user.DiscordID) {
+			err = b.store.ForgetUser(user)
+			if err != nil {
+				return "", err
+			}
+			return "Your user and all of your stats have been deleted.", nil
+		}
+		b.requestForget(user.DiscordID)
+		return fmt.Sprintf("This deletes your user and all of your stats for good. Use `!stats me forget confirm` within %d minutes to continue.", int(forgetTimeout.Minutes())), nil
+	}
+	return "", ERR_COMMAND_UNRECOGNIZED
+}
+
+// requestForget starts the confirmation window for deleting the user
+func (b *Bot) requestForget(discordID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.forget == nil {
+		b.forget = map[string]time.Time{}
+	}
+	b.forget[discordID] = time.Now().Add(forgetTimeout)
+}
+
+// confirmForget reports whether the user asked to be deleted within the confirmation window
+func (b *Bot) confirmForget(discordID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	expires, ok := b.forget[discordID]
+	delete(b.forget, discordID)
+	return ok && time.Now().Before(expires)
+}
@@ -0,0 +1,60 @@
+package statsbot
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestRenameUser(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+	u, _ := b.store.GetUser("alice")
+
+	for _, tc := range []struct {
+		name string
+		err  error
+	}{
+		{"Ålesund-Æøå-Ünïcødé", nil},
+		{"ポケモントレーナー", nil},
+		{"123456789012345678901", ERR_INVALID_NAME},
+		{"two words", ERR_INVALID_NAME},
+		{"", ERR_INVALID_NAME},
+		{"@everyone", ERR_INVALID_NAME},
+		{"<@1>", ERR_INVALID_NAME},
+		{"`code`", ERR_INVALID_NAME},
+		{"221247558008307713", ERR_INVALID_NAME},
+		{"trainer42", nil},
+		{"bob", ERR_NAME_TAKEN},
+	} {
+		if err := b.store.RenameUser(&u, tc.name); err != tc.err {
+			t.Errorf("RenameUser(%q) = %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestRepliesDontPing(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats ranks")
+
+	msgs := f.Messages()
+	if len(msgs) != 1 || msgs[0].AllowedMentions == nil || len(msgs[0].AllowedMentions.Parse) != 0 {
+		t.Errorf("ranks were sent as %+v, want no mentions allowed", msgs)
+	}
+}
+
+func TestRegisterAgainKeepsPickedName(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats me rename Trainer")
+	send(b, f, "1", "!stats user")
+
+	if u, _ := b.store.GetUserByDiscordID("1"); u.Name != "Trainer" || !u.CustomName {
+		t.Errorf("registering again changed the picked name to %+v", u)
+	}
+
+	if err := b.AddUser(&discordgo.User{ID: "3", Username: "bob"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := b.store.GetUserByDiscordID("3"); u.Name != "bob2" {
+		t.Errorf("a second bob registered as %q, want bob2", u.Name)
+	}
+}
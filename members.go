@@ -0,0 +1,176 @@
+package statsbot
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+)
+
+// MemberLeft marks the user inactive and records when they left the server
+func (s *Store) MemberLeft(u *User, at time.Time) error {
+	u.Active, u.LeftAt = false, &at
+	return s.db.Model(u).Updates(map[string]interface{}{"active": false, "left_at": at}).Error
+}
+
+// MemberReturned reactivates a user who left the server
+func (s *Store) MemberReturned(u *User) error {
+	u.Active, u.LeftAt = true, nil
+	return s.db.Model(u).Updates(map[string]interface{}{"active": true, "left_at": nil}).Error
+}
+
+// SyncName updates the user's name to their server name unless they picked their own.
+// A number is added to names another user already goes by.
+func (s *Store) SyncName(u *User, name string) error {
+	if u.CustomName || name == "" || name == u.Name {
+		return nil
+	}
+
+	name, err := s.freeName(u, name)
+	if err != nil || name == "" || name == u.Name {
+		return err
+	}
+	u.Name = name
+	return s.db.Model(u).Update("name", name).Error
+}
+
+// freeName returns name, or name with a number after it, whichever no other user
+// is known by first. It is empty if they all are.
+func (s *Store) freeName(u *User, name string) (string, error) {
+	for i := 1; i < 100; i++ {
+		candidate := name
+		if i > 1 {
+			suffix := strconv.Itoa(i)
+			runes := []rune(name)
+			if len(runes)+len(suffix) > 20 {
+				runes = runes[:20-len(suffix)]
+			}
+			candidate = string(runes) + suffix
+		}
+
+		taken, err := s.nameTaken(u, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+	return "", nil
+}
+
+// nameTaken reports whether a user other than u goes by name or has it as their discord ID
+func (s *Store) nameTaken(u *User, name string) (bool, error) {
+	var count int
+	res := s.db.Model(&User{}).Where("(name = ? OR discord_id = ?) AND id <> ?", name, name, u.ID).Count(&count)
+	return count > 0, res.Error
+}
+
+// memberName is the name the member shows up as in the server, cut to fit User.Name
+func memberName(m *discordgo.Member) string {
+	runes := []rune(displayName(m))
+	if len(runes) > 20 {
+		runes = runes[:20]
+	}
+	return string(runes)
+}
+
+// memberUser returns the stats user for the member, ok is false if they never joined the stats program
+func (b *Bot) memberUser(m *discordgo.Member) (user User, ok bool) {
+	if m == nil || m.User == nil {
+		return user, false
+	}
+
+	user, err := b.store.GetUserByDiscordID(m.User.ID)
+	if err == gorm.ErrRecordNotFound {
+		return user, false
+	} else if err != nil {
+		slog.Error("Unable to get member", "guild", m.GuildID, "user", m.User.ID, "error", err)
+		return user, false
+	}
+	return user, true
+}
+
+// HandleMemberJoin reactivates a user who comes back to the server
+func (b *Bot) HandleMemberJoin(m *discordgo.Member) {
+	if !b.begin() {
+		return
+	}
+	defer b.inflight.Done()
+
+	user, ok := b.memberUser(m)
+	if !ok {
+		return
+	}
+	log := slog.With("guild", m.GuildID, "user", user.DiscordID)
+
+	if user.LeftAt != nil {
+		if err := b.store.MemberReturned(&user); err != nil {
+			log.Error("Unable to reactivate member", "error", err)
+			return
+		}
+		log.Info("Member returned")
+	}
+	if err := b.store.SyncName(&user, memberName(m)); err != nil {
+		log.Error("Unable to sync member name", "error", err)
+	}
+	if err := b.store.SyncTeam(&user, memberTeam(b.session, m.GuildID, m)); err != nil {
+		log.Error("Unable to sync member team", "error", err)
+	}
+}
+
+// HandleMemberUpdate keeps the user's name and team in step with their nickname and roles
+func (b *Bot) HandleMemberUpdate(m *discordgo.Member) {
+	if !b.begin() {
+		return
+	}
+	defer b.inflight.Done()
+
+	user, ok := b.memberUser(m)
+	if !ok {
+		return
+	}
+
+	log := slog.With("guild", m.GuildID, "user", user.DiscordID)
+
+	if err := b.store.SyncName(&user, memberName(m)); err != nil {
+		log.Error("Unable to sync member name", "error", err)
+	}
+	if err := b.store.SyncTeam(&user, memberTeam(b.session, m.GuildID, m)); err != nil {
+		log.Error("Unable to sync member team", "error", err)
+	}
+}
+
+// HandleMemberLeave marks a user who left the server inactive, they drop off the boards after the LeaveGraceDays
+func (b *Bot) HandleMemberLeave(m *discordgo.Member) {
+	if !b.begin() {
+		return
+	}
+	defer b.inflight.Done()
+
+	user, ok := b.memberUser(m)
+	if !ok {
+		return
+	}
+	log := slog.With("guild", m.GuildID, "user", user.DiscordID)
+
+	if err := b.store.MemberLeft(&user, time.Now()); err != nil {
+		log.Error("Unable to deactivate member", "error", err)
+		return
+	}
+	log.Info("Member left")
+}
+
+func (b *Bot) memberAddHandler(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	b.HandleMemberJoin(m.Member)
+}
+
+func (b *Bot) memberUpdateHandler(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	b.HandleMemberUpdate(m.Member)
+}
+
+func (b *Bot) memberRemoveHandler(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	b.HandleMemberLeave(m.Member)
+}
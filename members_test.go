@@ -0,0 +1,76 @@
+package statsbot
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMemberNameSync(t *testing.T) {
+	b, f := newTestBot(t, nil)
+
+	update := func(id, nick string) string {
+		b.HandleMemberUpdate(&discordgo.Member{GuildID: "g", User: &discordgo.User{ID: id, Username: "user" + id}, Nick: nick})
+		u, err := b.store.GetUserByDiscordID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Name
+	}
+
+	if got := update("1", "Ally"); got != "Ally" {
+		t.Errorf("alice synced to %q, want Ally", got)
+	}
+	if got := update("2", "Ally"); got != "Ally2" {
+		t.Errorf("bob took alice's name as %q, want Ally2", got)
+	}
+	if got := update("2", "1"); got != "12" {
+		t.Errorf("bob took alice's discord ID as %q, want 12", got)
+	}
+	if got := update("1", "ThisNicknameIsFarTooLong"); got != "ThisNicknameIsFarToo" {
+		t.Errorf("long nickname synced to %q", got)
+	}
+
+	send(b, f, "1", "!stats add collector 100")
+	c, _ := b.store.GetCategory("collector")
+	bob, _ := b.store.GetUserByDiscordID("2")
+	if _, err := b.store.GetStat(c, bob); err == nil {
+		t.Error("alice's submission was saved for bob")
+	}
+}
+
+func TestMemberLeaveAndReturn(t *testing.T) {
+	b, f := newTestBot(t, &Config{LeaveGraceDays: 1})
+	send(b, f, "1", "!stats add collector 100")
+	c, _ := b.store.GetCategory("collector")
+
+	alice := &discordgo.Member{GuildID: "g", User: &discordgo.User{ID: "1", Username: "alice"}}
+	b.HandleMemberLeave(alice)
+	if u, _ := b.store.GetUserByDiscordID("1"); u.Active || u.LeftAt == nil {
+		t.Fatalf("alice is still active after leaving: %+v", u)
+	}
+	if stats, _ := b.store.GetAll(c); len(stats) != 1 {
+		t.Errorf("alice should stay on the board during the grace period, got %d stats", len(stats))
+	}
+
+	b.HandleMemberJoin(alice)
+	if u, _ := b.store.GetUserByDiscordID("1"); !u.Active || u.LeftAt != nil {
+		t.Errorf("alice is inactive after returning: %+v", u)
+	}
+}
+
+func TestRegisteredNameSticks(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	carol := &discordgo.User{ID: "3", Username: "carol"}
+	f.AddMember("g", carol, "")
+	f.AddChannel("g", "c")
+
+	if got := send(b, f, testAdminID, "!stats user carol TrainerCarol"); len(got) != 1 || got[0] != "Successfully added user!" {
+		t.Fatalf("register replied %q", got)
+	}
+	b.HandleMemberUpdate(&discordgo.Member{GuildID: "g", User: carol})
+
+	if u, _ := b.store.GetUserByDiscordID("3"); u.Name != "TrainerCarol" || !u.CustomName {
+		t.Errorf("a member update reset the registered name: %+v", u)
+	}
+}
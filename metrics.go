@@ -0,0 +1,238 @@
+package statsbot
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// botMetrics are the prometheus metrics for one bot
+type botMetrics struct {
+	registry        *prometheus.Registry
+	commands        *prometheus.CounterVec
+	commandDuration *prometheus.HistogramVec
+	discordRequests *prometheus.CounterVec
+	discordDuration *prometheus.HistogramVec
+	reconnects      prometheus.Counter
+}
+
+func newBotMetrics(store *Store) *botMetrics {
+	m := &botMetrics{
+		registry: prometheus.NewRegistry(),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "statsbot_commands_total",
+			Help: "Commands handled by name and outcome.",
+		}, []string{"command", "outcome"}),
+		commandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "statsbot_command_duration_seconds",
+			Help:    "Time taken to handle a command.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"command"}),
+		discordRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "statsbot_discord_requests_total",
+			Help: "Discord API calls by method and outcome.",
+		}, []string{"method", "outcome"}),
+		discordDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "statsbot_discord_request_duration_seconds",
+			Help:    "Discord API call latency.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "statsbot_gateway_reconnects_total",
+			Help: "Times the discord gateway connection was re-established.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.commands,
+		m.commandDuration,
+		m.discordRequests,
+		m.discordDuration,
+		m.reconnects,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	m.registry.MustRegister(store.metrics.dbDuration, store.metrics.dbErrors, store.metrics.submissions)
+
+	return m
+}
+
+func (m *botMetrics) observeCommand(command, outcome string, d time.Duration) {
+	m.commands.WithLabelValues(command, outcome).Inc()
+	m.commandDuration.WithLabelValues(command).Observe(d.Seconds())
+}
+
+// MetricsHandler returns the prometheus /metrics handler for the bot
+func (b *Bot) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(b.metrics.registry, promhttp.HandlerOpts{})
+}
+
+// storeMetrics are the prometheus metrics for a stats database
+type storeMetrics struct {
+	dbDuration  *prometheus.HistogramVec
+	dbErrors    *prometheus.CounterVec
+	submissions *prometheus.CounterVec
+}
+
+func newStoreMetrics() *storeMetrics {
+	return &storeMetrics{
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "statsbot_db_query_duration_seconds",
+			Help:    "Database query latency by operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+		dbErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "statsbot_db_errors_total",
+			Help: "Database queries that failed by operation.",
+		}, []string{"operation"}),
+		submissions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "statsbot_submissions_total",
+			Help: "Stats submitted per category.",
+		}, []string{"category"}),
+	}
+}
+
+const (
+	metricsKey   = "statsbot:metrics"
+	queryStarted = "statsbot:started"
+)
+
+// instrument returns db with every query run through it timed by m
+func (m *storeMetrics) instrument(db *gorm.DB) *gorm.DB {
+	registerDBCallbacks(db.Callback())
+	return db.Set(metricsKey, m)
+}
+
+// registerDBCallbacks adds the timing callbacks, gorm shares them between
+// connections so they look up the store's metrics from the scope
+func registerDBCallbacks(callbacks *gorm.Callback) {
+	if callbacks.Query().Get("statsbot:start") != nil {
+		return
+	}
+
+	start := func(scope *gorm.Scope) {
+		scope.InstanceSet(queryStarted, time.Now())
+	}
+	observe := func(operation string) func(scope *gorm.Scope) {
+		return func(scope *gorm.Scope) {
+			v, ok := scope.Get(metricsKey)
+			if !ok {
+				return
+			}
+			t, ok := scope.InstanceGet(queryStarted)
+			if !ok {
+				return
+			}
+
+			m := v.(*storeMetrics)
+			m.dbDuration.WithLabelValues(operation).Observe(time.Since(t.(time.Time)).Seconds())
+			if scope.HasError() && scope.DB().Error != gorm.ErrRecordNotFound {
+				m.dbErrors.WithLabelValues(operation).Inc()
+			}
+		}
+	}
+
+	callbacks.Create().Before("gorm:begin_transaction").Register("statsbot:start", start)
+	callbacks.Create().After("gorm:commit_or_rollback_transaction").Register("statsbot:observe", observe("create"))
+	callbacks.Query().Before("gorm:query").Register("statsbot:start", start)
+	callbacks.Query().After("gorm:after_query").Register("statsbot:observe", observe("query"))
+	callbacks.Update().Before("gorm:begin_transaction").Register("statsbot:start", start)
+	callbacks.Update().After("gorm:commit_or_rollback_transaction").Register("statsbot:observe", observe("update"))
+	callbacks.Delete().Before("gorm:begin_transaction").Register("statsbot:start", start)
+	callbacks.Delete().After("gorm:commit_or_rollback_transaction").Register("statsbot:observe", observe("delete"))
+	callbacks.RowQuery().Before("gorm:row_query").Register("statsbot:start", start)
+	callbacks.RowQuery().After("gorm:row_query").Register("statsbot:observe", observe("row_query"))
+}
+
+// instrumentedSession records the latency and errors of every discord call and logs the failures
+type instrumentedSession struct {
+	Session
+	metrics *botMetrics
+}
+
+func (s instrumentedSession) observe(method string, start time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+		slog.Warn("Discord request failed", "method", method, "error", err)
+	}
+	s.metrics.discordRequests.WithLabelValues(method, outcome).Inc()
+	s.metrics.discordDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func (s instrumentedSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	start := time.Now()
+	msg, err := s.Session.ChannelMessageSend(channelID, content)
+	s.observe("ChannelMessageSend", start, err)
+	return msg, err
+}
+
+func (s instrumentedSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	start := time.Now()
+	msg, err := s.Session.ChannelMessageSendEmbed(channelID, embed)
+	s.observe("ChannelMessageSendEmbed", start, err)
+	return msg, err
+}
+
+func (s instrumentedSession) ChannelFileSend(channelID, name string, r io.Reader) (*discordgo.Message, error) {
+	start := time.Now()
+	msg, err := s.Session.ChannelFileSend(channelID, name, r)
+	s.observe("ChannelFileSend", start, err)
+	return msg, err
+}
+
+func (s instrumentedSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	start := time.Now()
+	msg, err := s.Session.ChannelMessageSendComplex(channelID, data)
+	s.observe("ChannelMessageSendComplex", start, err)
+	return msg, err
+}
+
+func (s instrumentedSession) User(userID string) (*discordgo.User, error) {
+	start := time.Now()
+	u, err := s.Session.User(userID)
+	s.observe("User", start, err)
+	return u, err
+}
+
+func (s instrumentedSession) Channel(channelID string) (*discordgo.Channel, error) {
+	start := time.Now()
+	c, err := s.Session.Channel(channelID)
+	s.observe("Channel", start, err)
+	return c, err
+}
+
+func (s instrumentedSession) Guild(guildID string) (*discordgo.Guild, error) {
+	start := time.Now()
+	g, err := s.Session.Guild(guildID)
+	s.observe("Guild", start, err)
+	return g, err
+}
+
+func (s instrumentedSession) GuildMembers(guildID string, after string, limit int) ([]*discordgo.Member, error) {
+	start := time.Now()
+	members, err := s.Session.GuildMembers(guildID, after, limit)
+	s.observe("GuildMembers", start, err)
+	return members, err
+}
+
+func (s instrumentedSession) GuildMembersSearch(guildID, query string, limit int) ([]*discordgo.Member, error) {
+	start := time.Now()
+	members, err := s.Session.GuildMembersSearch(guildID, query, limit)
+	s.observe("GuildMembersSearch", start, err)
+	return members, err
+}
+
+func (s instrumentedSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error {
+	start := time.Now()
+	err := s.Session.InteractionRespond(interaction, resp)
+	s.observe("InteractionRespond", start, err)
+	return err
+}
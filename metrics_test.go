@@ -0,0 +1,32 @@
+package statsbot
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add collector 100")
+	send(b, f, "2", "!stats add collector 200")
+	send(b, f, "1", "!stats add nosuch 5")
+
+	rec := httptest.NewRecorder()
+	b.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`statsbot_commands_total{command="add",outcome="ok"} 2`,
+		`statsbot_commands_total{command="add",outcome="error"} 1`,
+		`statsbot_command_duration_seconds_count{command="add"} 3`,
+		`statsbot_submissions_total{category="collector"} 2`,
+		`statsbot_discord_requests_total{method="ChannelMessageSendComplex",outcome="ok"} 3`,
+		`statsbot_db_query_duration_seconds_count{operation="create"}`,
+	} {
+		if !strings.Contains(string(body), want+"\n") && !strings.Contains(string(body), want+" ") {
+			t.Errorf("metrics are missing %s", want)
+		}
+	}
+}
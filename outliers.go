@@ -0,0 +1,109 @@
+package statsbot
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// outlierMinUsers is how many other users need a value before a category's z-scores are trusted
+const outlierMinUsers = 5
+
+// outlierMinHistory is how long a user's history has to be to know their usual weekly gain
+const outlierMinHistory = 14 * 24 * time.Hour
+
+const week = 7 * 24 * time.Hour
+
+// Outlier returns why v looks out of place for the user, compared to everyone
+// else's values and to the user's own history. It is empty if v looks fine,
+// zScore and gainFactor are the limits and 0 turns that check off.
+func (s *Store) Outlier(c Category, u User, v int, zScore, gainFactor float64) (string, error) {
+	if c.ValueType() == TypeDate {
+		return "", nil
+	}
+
+	if zScore > 0 {
+		stats, err := s.GetAll(c)
+		if err != nil {
+			return "", err
+		}
+		values := []float64{}
+		for _, stat := range stats {
+			if stat.UserID != u.ID {
+				values = append(values, float64(stat.Value))
+			}
+		}
+
+		if z, ok := standardScore(values, float64(v)); ok {
+			if c.LowerIsBetter {
+				z = -z
+			}
+			if z > zScore {
+				return fmt.Sprintf("%s of %s is far ahead of everyone else, %.1f standard deviations from the average of %s.", c.FullName, c.FormatValue(v), z, c.FormatValue(int(math.Round(mean(values))))), nil
+			}
+		}
+	}
+
+	if gainFactor > 0 {
+		weekly, last, ok, err := s.weeklyGain(c, u)
+		if err != nil {
+			return "", err
+		}
+		if ok && weekly > 0 {
+			weeks := math.Max(1, time.Since(last.CreatedAt).Hours()/week.Hours())
+			gain := c.Gain(last.Value, v)
+			if float64(gain) > gainFactor*weekly*weeks {
+				return fmt.Sprintf("%s improved by %s, more than %g times the usual %s a week.", c.FullName, c.FormatValue(gain), gainFactor, c.FormatValue(int(math.Round(weekly)))), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// weeklyGain returns how much the user's value usually improves in a week and
+// their latest history entry, ok is false until there is enough history to tell
+func (s *Store) weeklyGain(c Category, u User) (weekly float64, last StatHistory, ok bool, err error) {
+	var history []StatHistory
+	res := s.db.Where("user_id = ? AND category_id = ?", u.ID, c.ID).Order("created_at asc, id asc").Find(&history)
+	if res.Error != nil {
+		return 0, last, false, res.Error
+	}
+	if len(history) < 3 {
+		return 0, last, false, nil
+	}
+
+	first, last := history[0], history[len(history)-1]
+	span := last.CreatedAt.Sub(first.CreatedAt)
+	if span < outlierMinHistory {
+		return 0, last, false, nil
+	}
+	return float64(c.Gain(first.Value, last.Value)) / (span.Hours() / week.Hours()), last, true, nil
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// standardScore returns how many standard deviations v is above the mean of values,
+// ok is false when there are too few values or they are all the same
+func standardScore(values []float64, v float64) (z float64, ok bool) {
+	if len(values) < outlierMinUsers {
+		return 0, false
+	}
+
+	m := mean(values)
+	variance := 0.0
+	for _, x := range values {
+		variance += (x - m) * (x - m)
+	}
+	sd := math.Sqrt(variance / float64(len(values)))
+	if sd == 0 {
+		return 0, false
+	}
+	return (v - m) / sd, true
+}
@@ -0,0 +1,158 @@
+package statsbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// resolveLimit is how many members a guild search returns
+const resolveLimit = 10
+
+var snowflakePattern = regexp.MustCompile(`^\d{15,21}$`)
+
+var (
+	ERR_USER_NOT_FOUND      = errors.New("User not found.")
+	ERR_USER_NOT_IN_PROGRAM = errors.New("That user hasn't joined the stats program.")
+)
+
+// AmbiguousUserError is returned when a name matches more than one user
+type AmbiguousUserError struct {
+	Query      string
+	Candidates []string
+}
+
+func (e *AmbiguousUserError) Error() string {
+	return fmt.Sprintf("%s matches more than one user, did you mean: %s?", e.Query, strings.Join(e.Candidates, ", "))
+}
+
+// parseUserID returns the discord ID in a mention or a raw ID
+func parseUserID(q string) (string, bool) {
+	if match := mentionPattern.FindStringSubmatch(q); match != nil && match[0] == q {
+		return match[1], true
+	}
+	if snowflakePattern.MatchString(q) {
+		return q, true
+	}
+	return "", false
+}
+
+// displayName is how the member appears in the server
+func displayName(m *discordgo.Member) string {
+	if m.Nick != "" {
+		return m.Nick
+	}
+	if m.User.GlobalName != "" {
+		return m.User.GlobalName
+	}
+	return m.User.Username
+}
+
+// FindUsers returns the users whose name is q ignoring case, or whose discord ID is q
+func (s *Store) FindUsers(q string) ([]User, error) {
+	var users []User
+	res := s.db.Where("LOWER(name) = ? OR discord_id = ?", strings.ToLower(q), q).Order("name asc").Find(&users)
+	return users, res.Error
+}
+
+// ResolveMember finds the server member meant by q, which can be a mention, an ID,
+// or a username, nickname or display name in any case
+func (b *Bot) ResolveMember(s Session, m *discordgo.MessageCreate, q string) (*discordgo.Member, error) {
+	if id, ok := parseUserID(q); ok {
+		for _, u := range m.Mentions {
+			if u.ID == id {
+				return &discordgo.Member{GuildID: m.GuildID, User: u}, nil
+			}
+		}
+		u, err := s.User(id)
+		if err != nil {
+			return nil, ERR_USER_NOT_FOUND
+		}
+		return &discordgo.Member{GuildID: m.GuildID, User: u}, nil
+	}
+
+	guildID := m.GuildID
+	if guildID == "" {
+		channel, err := s.Channel(m.ChannelID)
+		if err != nil {
+			return nil, err
+		}
+		guildID = channel.GuildID
+	}
+
+	members, err := s.GuildMembersSearch(guildID, q, resolveLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// the search matches prefixes, an exact name wins over them
+	exact := []*discordgo.Member{}
+	for _, mem := range members {
+		for _, name := range []string{mem.Nick, mem.User.GlobalName, mem.User.Username} {
+			if name != "" && strings.EqualFold(name, q) {
+				exact = append(exact, mem)
+				break
+			}
+		}
+	}
+	if len(exact) > 0 {
+		members = exact
+	}
+
+	switch len(members) {
+	case 0:
+		return nil, ERR_USER_NOT_FOUND
+	case 1:
+		return members[0], nil
+	}
+
+	candidates := []string{}
+	for _, mem := range members {
+		candidates = append(candidates, fmt.Sprintf("%s (%s)", displayName(mem), mem.User.Username))
+	}
+	return nil, &AmbiguousUserError{Query: q, Candidates: candidates}
+}
+
+// ResolveUser finds the stats user meant by q, see ResolveMember. Names
+// are looked up in the stats database before searching the server.
+func (b *Bot) ResolveUser(ctx context.Context, s Session, m *discordgo.MessageCreate, q string) (User, error) {
+	if id, ok := parseUserID(q); ok {
+		q = id
+	}
+
+	users, err := b.store.FindUsers(q)
+	if err != nil {
+		return User{}, err
+	}
+	if len(users) == 1 {
+		return users[0], nil
+	} else if len(users) > 1 {
+		candidates := []string{}
+		for _, u := range users {
+			candidates = append(candidates, fmt.Sprintf("%s (%s)", u.Name, u.DiscordID))
+		}
+		return User{}, &AmbiguousUserError{Query: q, Candidates: candidates}
+	}
+
+	member, err := b.ResolveMember(s, m, q)
+	var ambiguous *AmbiguousUserError
+	if errors.As(err, &ambiguous) || err == ERR_USER_NOT_FOUND {
+		return User{}, err
+	} else if err != nil {
+		loggerFrom(ctx).Debug("Unable to search server members", "query", q, "error", err)
+		return User{}, ERR_USER_NOT_FOUND
+	}
+
+	users, err = b.store.FindUsers(member.User.ID)
+	if err != nil {
+		return User{}, err
+	}
+	if len(users) == 0 {
+		return User{}, ERR_USER_NOT_IN_PROGRAM
+	}
+	return users[0], nil
+}
@@ -0,0 +1,91 @@
+package statsbot
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func newResolverBot(t *testing.T) (*Bot, *FakeSession, *discordgo.MessageCreate) {
+	t.Helper()
+
+	b, f := newTestBot(t, nil)
+	f.AddMember("g", &discordgo.User{ID: "1", Username: "alice"}, "Ace")
+	f.AddMember("g", &discordgo.User{ID: "2", Username: "bob"}, "")
+	f.AddMember("g", &discordgo.User{ID: "3", Username: "alicia"}, "")
+	f.AddMember("g", &discordgo.User{ID: "4", Username: "champ"}, "")
+	f.AddMember("g", &discordgo.User{ID: "5", Username: "carl"}, "Champ")
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{GuildID: "g", ChannelID: "c", Mentions: []*discordgo.User{{ID: "2", Username: "bob"}}}}
+	return b, f, m
+}
+
+func TestResolveMember(t *testing.T) {
+	b, f, m := newResolverBot(t)
+
+	for _, tc := range []struct {
+		q         string
+		id        string
+		err       error
+		ambiguous bool
+	}{
+		{q: "alice", id: "1"},
+		{q: "ALICE", id: "1"},
+		{q: "ace", id: "1"},
+		{q: "alic", ambiguous: true},
+		{q: "alicia", id: "3"},
+		{q: "champ", ambiguous: true},
+		{q: "<@2>", id: "2"},
+		{q: "<@!3>", id: "3"},
+		{q: "123456789012345678", err: ERR_USER_NOT_FOUND},
+		{q: "zed", err: ERR_USER_NOT_FOUND},
+	} {
+		mem, err := b.ResolveMember(f, m, tc.q)
+		var ambiguous *AmbiguousUserError
+		switch {
+		case tc.ambiguous:
+			if !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 {
+				t.Errorf("%s: got %v, want two candidates", tc.q, err)
+			}
+		case err != tc.err:
+			t.Errorf("%s: got error %v, want %v", tc.q, err, tc.err)
+		case err == nil && mem.User.ID != tc.id:
+			t.Errorf("%s: got member %s, want %s", tc.q, mem.User.ID, tc.id)
+		}
+	}
+}
+
+func TestResolveUser(t *testing.T) {
+	b, f, m := newResolverBot(t)
+
+	for _, tc := range []struct {
+		q    string
+		name string
+		err  error
+	}{
+		{q: "Alice", name: "alice"},
+		{q: "ace", name: "alice"},
+		{q: "<@2>", name: "bob"},
+		{q: "2", name: "bob"},
+		{q: "alicia", err: ERR_USER_NOT_IN_PROGRAM},
+		{q: "zed", err: ERR_USER_NOT_FOUND},
+	} {
+		u, err := b.ResolveUser(context.Background(), f, m, tc.q)
+		if err != tc.err {
+			t.Errorf("%s: got error %v, want %v", tc.q, err, tc.err)
+		} else if err == nil && u.Name != tc.name {
+			t.Errorf("%s: got %s, want %s", tc.q, u.Name, tc.name)
+		}
+	}
+}
+
+func TestResolveMemberLooksUpTheChannelsGuild(t *testing.T) {
+	b, f, m := newResolverBot(t)
+	f.AddChannel("g", "c2")
+	m.GuildID, m.ChannelID = "", "c2"
+
+	if mem, err := b.ResolveMember(f, m, "bob"); err != nil || mem.User.ID != "2" {
+		t.Errorf("got %v, %v", mem, err)
+	}
+}
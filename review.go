@@ -0,0 +1,146 @@
+package statsbot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+)
+
+// reviewButtonPrefix starts the custom ID of the approve and reject buttons, it is followed by action:id
+const reviewButtonPrefix = "review:"
+
+// holdForReview saves p for a moderator and posts it to the mod channel with approve and reject buttons
+func (b *Bot) holdForReview(ctx context.Context, p PendingStat) error {
+	err := b.store.AddPending(&p)
+	if err != nil {
+		return err
+	}
+	loggerFrom(ctx).Info("Submission held for review", "pending", p.ID, "category", p.Category.Name, "reason", p.Reason)
+
+	if b.config.ModLogChannel != "" {
+		_, err = b.session.ChannelMessageSendComplex(b.config.ModLogChannel, &discordgo.MessageSend{
+			Content:    fmt.Sprintf("Needs review %s\nUse the buttons, or `!stats review approve %d` or `!stats review reject %d`.", p, p.ID, p.ID),
+			Components: reviewButtons(p.ID),
+		})
+		if err != nil {
+			loggerFrom(ctx).Warn("Unable to post submission for review", "pending", p.ID, "error", err)
+		}
+	}
+	return &HeldForReviewError{Pending: p}
+}
+
+// holdOutlier holds v for review if it looks out of place for the user. Outliers
+// are only held when there is a mod-log channel to tell moderators about them.
+func (b *Bot) holdOutlier(ctx context.Context, m *discordgo.MessageCreate, c Category, u User, v int, correction bool) error {
+	if b.config.ModLogChannel == "" {
+		return nil
+	}
+
+	reason, err := b.store.Outlier(c, u, v, b.config.OutlierZScore, b.config.OutlierGainFactor)
+	if err != nil || reason == "" {
+		return err
+	}
+	return b.holdForReview(ctx, PendingStat{Category: c, CategoryID: c.ID, User: u, UserID: u.ID, Value: v, Reason: reason, Correction: correction, ChannelID: m.ChannelID})
+}
+
+// reviewButtons are the approve and reject buttons for the pending submission
+func reviewButtons(id int) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Approve", Style: discordgo.SuccessButton, CustomID: fmt.Sprintf("%sapprove:%d", reviewButtonPrefix, id)},
+			discordgo.Button{Label: "Reject", Style: discordgo.DangerButton, CustomID: fmt.Sprintf("%sreject:%d", reviewButtonPrefix, id)},
+		}},
+	}
+}
+
+// HandleInteraction approves or rejects a pending submission when a moderator clicks one of its buttons
+func (b *Bot) HandleInteraction(i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+	data := i.MessageComponentData()
+	if !strings.HasPrefix(data.CustomID, reviewButtonPrefix) {
+		return
+	}
+
+	if !b.begin() {
+		return
+	}
+	defer b.inflight.Done()
+
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+	log := slog.Default().With("guild", i.GuildID, "channel", i.ChannelID, "user", user.ID, "button", data.CustomID)
+	ctx := withLogger(b.ctx, log)
+
+	respond := func(content string, update bool) {
+		resp := &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Content: content, Flags: discordgo.MessageFlagsEphemeral},
+		}
+		if update {
+			resp.Type = discordgo.InteractionResponseUpdateMessage
+			resp.Data = &discordgo.InteractionResponseData{Content: content, Components: []discordgo.MessageComponent{}}
+		}
+		if err := b.session.InteractionRespond(i.Interaction, resp); err != nil {
+			log.Warn("Unable to respond to interaction", "error", err)
+		}
+	}
+
+	if !b.store.CheckAdmin(user.ID) {
+		respond("Only admins can review submissions.", false)
+		return
+	}
+
+	action, id, _ := strings.Cut(strings.TrimPrefix(data.CustomID, reviewButtonPrefix), ":")
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		log.Warn("Invalid review button")
+		return
+	}
+
+	original := ""
+	if i.Message != nil {
+		original = i.Message.Content
+	}
+
+	p, err := b.store.GetPending(n)
+	if err == gorm.ErrRecordNotFound {
+		respond(original+"\nAlready reviewed.", true)
+		return
+	} else if err != nil {
+		log.Error("Unable to get pending submission", "error", err)
+		respond(err.Error(), false)
+		return
+	}
+
+	// decidePending audits the message that asked for the decision, here it is the review message
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{ChannelID: i.ChannelID, GuildID: i.GuildID, Author: user}}
+	if i.Message != nil {
+		m.ID = i.Message.ID
+	}
+	reply, err := b.decidePending(ctx, m, p, action)
+	if err == ERR_ALREADY_REVIEWED {
+		respond(original+"\nAlready reviewed.", true)
+		return
+	} else if err != nil {
+		respond(err.Error(), false)
+		return
+	}
+	log.Info("Reviewed submission", "pending", p.ID, "action", action)
+	respond(fmt.Sprintf("%s\n%s by %s.", original, strings.TrimSuffix(reply, "."), user.Username), true)
+}
+
+func (b *Bot) interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	b.HandleInteraction(i)
+}
@@ -0,0 +1,101 @@
+package statsbot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// addCrowd gives five more users collector values close to 100
+func addCrowd(t *testing.T, b *Bot) {
+	t.Helper()
+
+	c, _ := b.store.GetCategory("collector")
+	for i := 3; i < 8; i++ {
+		u := User{DiscordID: fmt.Sprint(i), Name: fmt.Sprint("user", i)}
+		if err := b.store.InsertUser(&u); err != nil {
+			t.Fatal(err)
+		}
+		b.store.NewStat(c, u, 95+i)
+	}
+}
+
+func click(b *Bot, userID, customID string) {
+	b.HandleInteraction(&discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:    discordgo.InteractionMessageComponent,
+		Data:    discordgo.MessageComponentInteractionData{CustomID: customID},
+		Member:  &discordgo.Member{User: &discordgo.User{ID: userID, Username: "mod"}},
+		Message: &discordgo.Message{ID: "review", Content: "Needs review"},
+	}})
+}
+
+func TestOutlierReviewButtons(t *testing.T) {
+	b, f := newTestBot(t, &Config{ModLogChannel: "modlog", OutlierZScore: 4})
+	addCrowd(t, b)
+
+	got := send(b, f, "1", "!stats add collector 100000")
+	if len(got) != 2 || !strings.HasSuffix(got[1], "A moderator will review it.") {
+		t.Fatalf("outlier replied %q", got)
+	}
+	held := f.Messages()[0]
+	if held.ChannelID != "modlog" || len(held.Components) != 1 {
+		t.Fatalf("review message was %+v", held)
+	}
+	pending, _ := b.store.GetPendingStats()
+	if len(pending) != 1 {
+		t.Fatalf("got %d pending submissions", len(pending))
+	}
+	approve := fmt.Sprintf("%sapprove:%d", reviewButtonPrefix, pending[0].ID)
+
+	f.Reset()
+	click(b, "2", approve)
+	if msgs := f.Messages(); len(msgs) != 1 || msgs[0].Content != "Only admins can review submissions." {
+		t.Errorf("a user's click got %+v", msgs)
+	}
+
+	f.Reset()
+	click(b, testAdminID, approve)
+	click(b, testAdminID, approve)
+	msgs := f.Messages()
+	approvals := 0
+	for _, msg := range msgs {
+		if strings.Contains(msg.Content, "was approved") {
+			approvals++
+		}
+	}
+	if approvals != 1 || !strings.HasSuffix(msgs[len(msgs)-1].Content, "Already reviewed.") {
+		t.Errorf("two clicks got %+v", msgs)
+	}
+	if v, _ := collectorValue(t, b, "alice"); v != 100000 {
+		t.Errorf("alice has %d after approval", v)
+	}
+}
+
+func TestOutliersNeedModLog(t *testing.T) {
+	b, f := newTestBot(t, &Config{OutlierZScore: 4})
+	addCrowd(t, b)
+
+	if got := send(b, f, "1", "!stats add collector 100000"); len(got) != 1 || got[0] != "Successfully added stat!" {
+		t.Errorf("outlier without a mod-log channel replied %q", got)
+	}
+}
+
+func TestOutlierFixIsHeld(t *testing.T) {
+	b, f := newTestBot(t, &Config{ModLogChannel: "modlog", OutlierZScore: 4})
+	addCrowd(t, b)
+	send(b, f, "1", "!stats add collector 100")
+
+	got := send(b, f, "1", "!stats fix collector 100000")
+	if len(got) != 2 || !strings.HasSuffix(got[1], "A moderator will review it.") {
+		t.Fatalf("outlier fix replied %q", got)
+	}
+	if v, _ := collectorValue(t, b, "alice"); v != 100 {
+		t.Errorf("alice has %d while the fix is held", v)
+	}
+	pending, _ := b.store.GetPendingStats()
+	if len(pending) != 1 || !pending[0].Correction {
+		t.Errorf("held %+v, want one correction", pending)
+	}
+}
@@ -0,0 +1,334 @@
+package statsbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	ERR_ALREADY_REVIEWED = errors.New("That submission has already been reviewed.")
+	ERR_PENDING_OUTDATED = errors.New("The user has submitted a newer value since, reject this one instead.")
+)
+
+// RuleViolation explains which of its category's rules a value breaks
+type RuleViolation struct {
+	Reason string
+}
+
+func (e *RuleViolation) Error() string {
+	return e.Reason
+}
+
+// HeldForReviewError is returned when a submission broke a rule and waits for a moderator
+type HeldForReviewError struct {
+	Pending PendingStat
+}
+
+func (e *HeldForReviewError) Error() string {
+	return fmt.Sprintf("%s A moderator will review it.", e.Pending.Reason)
+}
+
+// PendingStat is a submission that broke one of its category's rules and waits for a moderator
+type PendingStat struct {
+	ID         int `gorm:"primary_key"`
+	CreatedAt  time.Time
+	Category   Category
+	CategoryID int
+	User       User
+	UserID     int `gorm:"index"`
+	Value      int `gorm:"type:bigint"`
+	Reason     string
+
+	// Correction is set when the value came from !stats fix
+	Correction bool `gorm:"DEFAULT:false"`
+
+	// ChannelID is where the value was submitted, the outcome is posted there
+	ChannelID string `gorm:"size:20"`
+}
+
+func (PendingStat) TableName() string {
+	return "PendingStat"
+}
+
+// String formats the pending submission as a single line
+func (p PendingStat) String() string {
+	return fmt.Sprintf("#%d %s %s %s: %s", p.ID, p.CreatedAt.Format("2006-01-02 15:04"), p.User.Name, p.Category.FormatValue(p.Value), p.Reason)
+}
+
+// ruleBase is the value a submission is checked against
+type ruleBase struct {
+	Value int
+	At    time.Time
+}
+
+// Rules describes the category's sanity rules, it is empty if there are none
+func (c Category) Rules() string {
+	rules := []string{}
+	if c.Monotonic {
+		rules = append(rules, "never gets worse")
+	}
+	if c.MaxDailyGain > 0 {
+		rules = append(rules, fmt.Sprintf("at most %s a day", c.FormatValue(int(int64(c.MaxDailyGain)*c.scale()))))
+	}
+	if c.Ceiling != "" {
+		rules = append(rules, "at most "+c.Ceiling)
+	}
+	if len(rules) > 0 && c.Review {
+		rules = append(rules, "reviewed")
+	}
+	return strings.Join(rules, ", ")
+}
+
+// exceeds reports whether value a of category ca is more than value b of category cb, allowing for their decimals
+func exceeds(ca Category, a int, cb Category, b int) bool {
+	return int64(a)*cb.scale() > int64(b)*ca.scale()
+}
+
+// CheckRules returns a *RuleViolation if setting the user's value to v breaks the category's rules
+func (s *Store) CheckRules(c Category, u User, v int) error {
+	now := time.Now()
+	stat, err := s.GetStat(c, u)
+	if err == gorm.ErrRecordNotFound {
+		return s.checkRules(c, u, v, nil, nil, now)
+	} else if err != nil {
+		return err
+	}
+	base := &ruleBase{Value: stat.Value, At: stat.UpdatedAt}
+
+	daily, err := s.dailyBase(c, u, now, 0)
+	if err != nil {
+		return err
+	}
+	if daily == nil {
+		daily = base
+	}
+	return s.checkRules(c, u, v, base, daily, now)
+}
+
+// CheckCorrection is CheckRules for a correction, which replaces the latest
+// value so it is checked against the one before it
+func (s *Store) CheckCorrection(c Category, u User, v int) error {
+	now := time.Now()
+	var latest []StatHistory
+	res := s.db.Where("user_id = ? AND category_id = ?", u.ID, c.ID).Order("created_at desc, id desc").Limit(2).Find(&latest)
+	if res.Error != nil {
+		return res.Error
+	}
+	if len(latest) < 2 {
+		return s.checkRules(c, u, v, nil, nil, now)
+	}
+	base := &ruleBase{Value: latest[1].Value, At: latest[1].CreatedAt}
+
+	daily, err := s.dailyBase(c, u, now, latest[0].ID)
+	if err != nil {
+		return err
+	}
+	return s.checkRules(c, u, v, base, daily, now)
+}
+
+// dailyBase returns the user's value from a day before now, which is the newest
+// submission at least a day old, or their oldest one when every submission is
+// newer. It is nil if they have none besides the one with the ID skip.
+func (s *Store) dailyBase(c Category, u User, now time.Time, skip int) (*ruleBase, error) {
+	q := s.db.Where("user_id = ? AND category_id = ? AND id <> ?", u.ID, c.ID, skip)
+
+	var h StatHistory
+	res := q.Where("created_at <= ?", now.Add(-24*time.Hour)).Order("created_at desc, id desc").First(&h)
+	if res.Error == gorm.ErrRecordNotFound {
+		res = q.Order("created_at asc, id asc").First(&h)
+	}
+	if res.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if res.Error != nil {
+		return nil, res.Error
+	}
+	return &ruleBase{Value: h.Value, At: h.CreatedAt}, nil
+}
+
+// checkRules checks v against the rules, base is the value it replaces and
+// daily is the value from a day before, or the oldest one, that MaxDailyGain
+// is measured from. Measuring from a day back rather than the latest value keeps
+// many small submissions within the same daily limit as one large one.
+func (s *Store) checkRules(c Category, u User, v int, base, daily *ruleBase, now time.Time) error {
+	if base != nil && c.Monotonic && c.Gain(base.Value, v) < 0 {
+		return &RuleViolation{fmt.Sprintf("%s never gets worse, it can't go from %s to %s.", c.FullName, c.FormatValue(base.Value), c.FormatValue(v))}
+	}
+
+	if daily != nil && c.MaxDailyGain > 0 {
+		if gain := c.Gain(daily.Value, v); gain > 0 {
+			days := math.Max(1, now.Sub(daily.At).Hours()/24)
+			limit := int64(float64(int64(c.MaxDailyGain)*c.scale()) * days)
+			if int64(gain) > limit {
+				period := "a day"
+				if days >= 2 {
+					period = fmt.Sprintf("%d days", int(days))
+				}
+				return &RuleViolation{fmt.Sprintf("%s can improve by at most %s in %s, it can't go from %s to %s.", c.FullName, c.FormatValue(int(limit)), period, c.FormatValue(daily.Value), c.FormatValue(v))}
+			}
+		}
+	}
+
+	if c.Ceiling != "" {
+		ceiling, err := s.GetCategory(c.Ceiling)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if stat, err := s.GetStat(ceiling, u); err == nil && exceeds(c, v, ceiling, stat.Value) {
+			return &RuleViolation{fmt.Sprintf("%s can't be more than your %s of %s.", c.FullName, ceiling.FullName, ceiling.FormatValue(stat.Value))}
+		}
+	}
+
+	var capped []Category
+	res := s.db.Where("ceiling = ?", c.Name).Find(&capped)
+	if res.Error != nil {
+		return res.Error
+	}
+	for _, cc := range capped {
+		if stat, err := s.GetStat(cc, u); err == nil && exceeds(cc, stat.Value, c, v) {
+			return &RuleViolation{fmt.Sprintf("%s can't be less than your %s of %s.", c.FullName, cc.FullName, cc.FormatValue(stat.Value))}
+		}
+	}
+	return nil
+}
+
+// AddPending saves a submission for a moderator to review
+func (s *Store) AddPending(p *PendingStat) error {
+	return s.db.Set("gorm:save_associations", false).Create(p).Error
+}
+
+// GetPending returns the pending submission with the ID
+func (s *Store) GetPending(id int) (p PendingStat, err error) {
+	res := s.db.Preload("Category").Preload("User").First(&p, id)
+	return p, res.Error
+}
+
+// GetPendingStats returns every submission waiting for review, oldest first
+func (s *Store) GetPendingStats() ([]PendingStat, error) {
+	var pending []PendingStat
+	res := s.db.Preload("Category").Preload("User").Order("created_at asc, id asc").Find(&pending)
+	return pending, res.Error
+}
+
+// ApprovePending saves the pending value as the user's stat and removes it from review.
+// It returns ERR_ALREADY_REVIEWED if another moderator got to it first, and
+// ERR_PENDING_OUTDATED if the user has saved a newer value since.
+func (s *Store) ApprovePending(p PendingStat) error {
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	txs := s.withDB(tx)
+
+	err := txs.approvePending(p)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (s *Store) approvePending(p PendingStat) error {
+	err := s.RejectPending(p)
+	if err != nil {
+		return err
+	}
+
+	stat, err := s.GetStat(p.Category, p.User)
+	if err == nil && stat.UpdatedAt.After(p.CreatedAt) {
+		return ERR_PENDING_OUTDATED
+	} else if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	return s.saveStat(p.Category, p.User, p.Value, p.Correction, p.User.DiscordID)
+}
+
+// RejectPending removes the pending value without saving it, it returns
+// ERR_ALREADY_REVIEWED if the value was already removed
+func (s *Store) RejectPending(p PendingStat) error {
+	res := s.db.Where("id = ?", p.ID).Delete(&PendingStat{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != 1 {
+		return ERR_ALREADY_REVIEWED
+	}
+	return nil
+}
+
+// enforceRules turns a rule violation into a pending review when the category
+// asks for one, other errors from a rule check are returned as they are
+func (b *Bot) enforceRules(ctx context.Context, m *discordgo.MessageCreate, c Category, u User, v int, correction bool, err error) error {
+	var violation *RuleViolation
+	if !errors.As(err, &violation) || !c.Review {
+		return err
+	}
+
+	return b.holdForReview(ctx, PendingStat{Category: c, CategoryID: c.ID, User: u, UserID: u.ID, Value: v, Reason: violation.Reason, Correction: correction, ChannelID: m.ChannelID})
+}
+
+// Review runs the !stats review commands, listing the pending submissions or approving or rejecting one
+func (b *Bot) Review(ctx context.Context, m *discordgo.MessageCreate, args []string) (string, error) {
+	if len(args) == 0 || args[0] == "" {
+		pending, err := b.store.GetPendingStats()
+		if err != nil {
+			return "", err
+		}
+		if len(pending) == 0 {
+			return "Nothing to review.", nil
+		}
+		lines := []string{}
+		for _, p := range pending {
+			lines = append(lines, p.String())
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	if len(args) != 2 {
+		return "", errors.New("Use `!stats review`, `!stats review approve {id}` or `!stats review reject {id}`.")
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+	if err != nil {
+		return "", ERR_INVALID_VALUE
+	}
+	p, err := b.store.GetPending(id)
+	if err == gorm.ErrRecordNotFound {
+		return "", fmt.Errorf("There is no pending submission #%d.", id)
+	} else if err != nil {
+		return "", err
+	}
+
+	return b.decidePending(ctx, m, p, strings.ToLower(args[0]))
+}
+
+// decidePending approves or rejects p as the author of m and tells the submitter
+func (b *Bot) decidePending(ctx context.Context, m *discordgo.MessageCreate, p PendingStat, action string) (string, error) {
+	var err error
+	var result string
+	switch action {
+	case "approve":
+		err, result = b.store.ApprovePending(p), "approved"
+	case "reject":
+		err, result = b.store.RejectPending(p), "rejected"
+	default:
+		return "", ERR_COMMAND_UNRECOGNIZED
+	}
+	if err != nil {
+		return "", err
+	}
+
+	b.audit(ctx, m, AuditEntry{Action: action, TargetID: p.User.DiscordID, Target: p.User.Name, Category: p.Category.Name, NewValue: p.Category.FormatValue(p.Value)})
+
+	if p.ChannelID != "" {
+		_, _ = b.session.ChannelMessageSend(p.ChannelID, fmt.Sprintf("<@%s> your %s value of %s was %s.", p.User.DiscordID, p.Category.FullName, p.Category.FormatValue(p.Value), result))
+	}
+	return fmt.Sprintf("Submission #%d %s.", p.ID, result), nil
+}
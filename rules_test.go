@@ -0,0 +1,149 @@
+package statsbot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// backdate moves the user's whole collector history back by d
+func backdate(t *testing.T, s *Store, u User, d time.Duration) {
+	t.Helper()
+
+	var history []StatHistory
+	s.db.Where("user_id = ?", u.ID).Find(&history)
+	for _, h := range history {
+		if err := s.db.Model(&h).UpdateColumn("created_at", h.CreatedAt.Add(-d)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.db.Model(&Stat{}).Where("user_id = ?", u.ID).UpdateColumn("updated_at", time.Now().Add(-d))
+}
+
+func rulesStore(t *testing.T) (*Store, Category, User) {
+	s := newTestStore(t)
+	c, _ := s.GetCategory("collector")
+	c.Monotonic, c.MaxDailyGain = true, 100
+	if err := s.UpdateCategory(&c); err != nil {
+		t.Fatal(err)
+	}
+	u := User{DiscordID: "1", Name: "alice"}
+	if err := s.InsertUser(&u); err != nil {
+		t.Fatal(err)
+	}
+	return s, c, u
+}
+
+func isViolation(err error) bool {
+	var violation *RuleViolation
+	return errors.As(err, &violation)
+}
+
+func TestCheckRulesMonotonic(t *testing.T) {
+	s, c, u := rulesStore(t)
+	s.NewStat(c, u, 500)
+
+	if err := s.CheckRules(c, u, 499); !isViolation(err) {
+		t.Errorf("going down got %v", err)
+	}
+	if err := s.CheckRules(c, u, 550); err != nil {
+		t.Errorf("going up got %v", err)
+	}
+}
+
+func TestCheckRulesDailyGainWindow(t *testing.T) {
+	s, c, u := rulesStore(t)
+	s.NewStat(c, u, 500)
+	backdate(t, s, u, 48*time.Hour)
+
+	// two idle days allow 200
+	if err := s.CheckRules(c, u, 700); err != nil {
+		t.Errorf("gain after two days got %v", err)
+	}
+	if err := s.CheckRules(c, u, 701); !isViolation(err) {
+		t.Errorf("too much gain after two days got %v", err)
+	}
+
+	// small steps add up to the same limit as one big one, here 125 for the 30
+	// hours since the value from a day back
+	s.NewStat(c, u, 600)
+	backdate(t, s, u, 30*time.Hour)
+	for _, v := range []int{650, 700, 725} {
+		if err := s.CheckRules(c, u, v); err != nil {
+			t.Fatalf("%d got %v", v, err)
+		}
+		s.NewStat(c, u, v)
+	}
+	if err := s.CheckRules(c, u, 726); !isViolation(err) {
+		t.Errorf("small steps past the daily limit got %v", err)
+	}
+	if err := s.CheckCorrection(c, u, 720); err != nil {
+		t.Errorf("correcting the latest value got %v", err)
+	}
+}
+
+func TestCheckRulesCeiling(t *testing.T) {
+	s, c, u := rulesStore(t)
+	gyms, _ := s.GetCategory("goldgyms")
+	gyms.Ceiling = "collector"
+	s.UpdateCategory(&gyms)
+	s.NewStat(c, u, 50)
+
+	if err := s.CheckRules(gyms, u, 51); !isViolation(err) {
+		t.Errorf("going over the ceiling got %v", err)
+	}
+	if err := s.CheckRules(gyms, u, 50); err != nil {
+		t.Errorf("matching the ceiling got %v", err)
+	}
+	s.NewStat(gyms, u, 50)
+	if err := s.CheckRules(c, u, 50); err != nil {
+		t.Errorf("unchanged ceiling got %v", err)
+	}
+}
+
+func TestApprovePending(t *testing.T) {
+	s, c, u := rulesStore(t)
+	s.NewStat(c, u, 100)
+
+	p := PendingStat{Category: c, CategoryID: c.ID, User: u, UserID: u.ID, Value: 5000, Reason: "too much"}
+	if err := s.AddPending(&p); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApprovePending(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApprovePending(p); err != ERR_ALREADY_REVIEWED {
+		t.Errorf("second approval got %v", err)
+	}
+	if err := s.RejectPending(p); err != ERR_ALREADY_REVIEWED {
+		t.Errorf("rejecting an approved value got %v", err)
+	}
+	if stat, _ := s.GetStat(c, u); stat.Value != 5000 {
+		t.Errorf("got %d after approval", stat.Value)
+	}
+	var count int
+	s.db.Model(&StatHistory{}).Where("user_id = ?", u.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("got %d history rows, the value was saved more than once", count)
+	}
+}
+
+func TestApprovePendingOutdated(t *testing.T) {
+	s, c, u := rulesStore(t)
+
+	p := PendingStat{Category: c, CategoryID: c.ID, User: u, UserID: u.ID, Value: 5000, Reason: "too much"}
+	s.AddPending(&p)
+	s.db.Model(&p).UpdateColumn("created_at", time.Now().Add(-time.Hour))
+	p, _ = s.GetPending(p.ID)
+	s.NewStat(c, u, 200)
+
+	if err := s.ApprovePending(p); err != ERR_PENDING_OUTDATED {
+		t.Errorf("approving an outdated value got %v", err)
+	}
+	if stat, _ := s.GetStat(c, u); stat.Value != 200 {
+		t.Errorf("the newer value was overwritten with %d", stat.Value)
+	}
+	if _, err := s.GetPending(p.ID); err != nil {
+		t.Errorf("an outdated value should stay pending so it can be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package statsbot
+
+import (
+	"io"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session is the part of the discord API the bot commands use
+type Session interface {
+	ChannelMessageSend(channelID, content string) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	ChannelFileSend(channelID, name string, r io.Reader) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error)
+	User(userID string) (*discordgo.User, error)
+	Channel(channelID string) (*discordgo.Channel, error)
+	Guild(guildID string) (*discordgo.Guild, error)
+	GuildMembers(guildID string, after string, limit int) ([]*discordgo.Member, error)
+	GuildMembersSearch(guildID, query string, limit int) ([]*discordgo.Member, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error
+}
+
+// discordSession adapts a live discordgo session to the Session interface
+type discordSession struct {
+	s *discordgo.Session
+}
+
+func (d discordSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return d.s.ChannelMessageSend(channelID, content)
+}
+
+func (d discordSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return d.s.ChannelMessageSendEmbed(channelID, embed)
+}
+
+func (d discordSession) ChannelFileSend(channelID, name string, r io.Reader) (*discordgo.Message, error) {
+	return d.s.ChannelFileSend(channelID, name, r)
+}
+
+func (d discordSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	return d.s.ChannelMessageSendComplex(channelID, data)
+}
+
+func (d discordSession) User(userID string) (*discordgo.User, error) {
+	return d.s.User(userID)
+}
+
+func (d discordSession) Channel(channelID string) (*discordgo.Channel, error) {
+	return d.s.Channel(channelID)
+}
+
+func (d discordSession) Guild(guildID string) (*discordgo.Guild, error) {
+	return d.s.Guild(guildID)
+}
+
+func (d discordSession) GuildMembers(guildID string, after string, limit int) ([]*discordgo.Member, error) {
+	return d.s.GuildMembers(guildID, after, limit)
+}
+
+func (d discordSession) GuildMembersSearch(guildID, query string, limit int) ([]*discordgo.Member, error) {
+	return d.s.GuildMembersSearch(guildID, query, limit)
+}
+
+func (d discordSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse) error {
+	return d.s.InteractionRespond(interaction, resp)
+}
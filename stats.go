@@ -1,10 +1,9 @@
 package statsbot
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
-	"strconv"
 	"strings"
 	"time"
 
@@ -16,16 +15,18 @@ var (
 	ERR_INVALID_VALUE = errors.New("Invalid value.")
 )
 
-func AddStat(author *discordgo.User, msg string) (err error) {
+// AddStat sets the stat in msg for the author of m, admins can set other users' stats and those changes are audited
+func (b *Bot) AddStat(ctx context.Context, m *discordgo.MessageCreate, msg string) (err error) {
+	author := m.Author
 	msg = strings.ToLower(msg)
 	fields := strings.Split(msg, " ")
 
 	//category [user] value
 
-	var c,u,v string
+	var c, u, v string
 	if len(fields) == 3 {
 		// Admin entering other user
-		if !CheckAdmin(author.ID) {
+		if !b.store.CheckAdmin(author.ID) {
 			return ERR_NOT_ADMIN
 		}
 
@@ -36,35 +37,62 @@ func AddStat(author *discordgo.User, msg string) (err error) {
 	} else {
 		return ERR_INVALID_VALUE
 	}
-	category, err := GetCategory(c)
+	category, err := b.store.GetCategory(c)
 	if err != nil {
-		log.Printf("Unable to get category: %+v\n", err.Error())
+		loggerFrom(ctx).Debug("Unable to get category", "category", c, "error", err)
 		return err
 	}
 
-	user, err := GetUser(u)
+	var user User
+	if u == author.ID {
+		user, err = b.store.GetUserByDiscordID(u)
+	} else {
+		user, err = b.ResolveUser(ctx, b.session, m, u)
+	}
 	if err != nil {
-		log.Printf("Unable to get user: %+v\n", err.Error())
+		loggerFrom(ctx).Debug("Unable to get user", "user", u, "error", err)
 		return err
 	}
 
-	value, err := strconv.Atoi(v)
+	value, err := category.ParseValue(v)
 	if err != nil {
-		log.Printf("Invalid value: %+v\n", err.Error())
+		loggerFrom(ctx).Debug("Invalid value", "value", v, "error", err)
 		return ERR_INVALID_VALUE
 	}
 
-	err = category.AddStat(user, value)
+	if !b.store.CheckAdmin(author.ID) {
+		err = b.enforceRules(ctx, m, category, user, value, false, b.store.CheckRules(category, user, value))
+		if err != nil {
+			return err
+		}
+
+		err = b.holdOutlier(ctx, m, category, user, value, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	old, oldErr := b.store.GetStat(category, user)
+
+	err = b.store.SubmitStat(category, user, value, author.ID)
 	if err != nil {
-		log.Printf(err.Error())
+		loggerFrom(ctx).Debug("Unable to add stat", "category", category.Name, "user", user.DiscordID, "error", err)
 		return err
 	}
 
+	if user.DiscordID != author.ID {
+		e := AuditEntry{Action: "set", TargetID: user.DiscordID, Target: user.Name, Category: category.Name, NewValue: category.FormatValue(value)}
+		if oldErr == nil {
+			e.OldValue = category.FormatValue(old.Value)
+		}
+		b.audit(ctx, m, e)
+	}
+
 	return
 
 }
 
-func AddUser(user *discordgo.User, name string) (err error) {
+func (b *Bot) AddUser(user *discordgo.User, name string) (err error) {
 	if user == nil {
 		return errors.New("User not found.")
 	}
@@ -77,55 +105,103 @@ func AddUser(user *discordgo.User, name string) (err error) {
 		Name:      name,
 	}
 
-	u.Insert()
+	return b.store.InsertUser(&u)
+}
+
+// RegisterMember adds the member named by args[0] to the stats program, under the name args[1] if it is given
+func (b *Bot) RegisterMember(ctx context.Context, s Session, m *discordgo.MessageCreate, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("Use `!stats user {user} [name]`.")
+	}
 
+	member, err := b.ResolveMember(s, m, args[0])
+	if err != nil {
+		return err
+	}
+
+	// a name given by the admin is kept like one the user picked, it doesn't follow their nickname
+	u := User{DiscordID: member.User.ID, Name: memberName(member)}
+	if len(args) == 2 {
+		if !validName(args[1]) {
+			return ERR_INVALID_NAME
+		}
+		u.Name, u.CustomName = args[1], true
+	}
+
+	previous, prevErr := b.store.GetUserByDiscordID(u.DiscordID)
+
+	err = b.store.InsertUser(&u)
+	if err != nil {
+		return err
+	}
+
+	// registering someone again can rename them, the entry shows what they were called
+	e := AuditEntry{Action: "register", TargetID: u.DiscordID, Target: u.Name}
+	if prevErr == nil {
+		e.OldValue, e.NewValue = previous.Name, u.Name
+	}
+	b.audit(ctx, m, e)
 	return nil
 }
 
-func PrintStats(msg string) (string, error) {
+func (b *Bot) PrintStats(ctx context.Context, msg string) (string, error) {
 	msg = strings.ToLower(msg)
 	fields := strings.Split(msg, " ")
 
-	if len(fields) == 1 {
+	if len(fields) == 1 || len(fields) == 2 {
 		c := fields[0]
 
-		category, err := GetCategory(c)
+		category, err := b.store.GetCategory(c)
 		if err != nil {
-			log.Printf("Unable to get category: %+v\n", err.Error())
+			loggerFrom(ctx).Debug("Unable to get category", "category", c, "error", err)
 			return "", err
 		}
 
-		return category.PrintStats()
+		team := ""
+		if len(fields) == 2 {
+			var ok bool
+			if team, ok = parseTeam(fields[1]); !ok {
+				return "", ERR_INVALID_TEAM
+			}
+		}
+
+		return b.store.PrintStats(category, team)
 
 	}
-		return "", nil
+	return "", nil
 }
 
-func PrintRanks(u User) (string, error) {
-	categories, err := GetCategories()
+func (b *Bot) PrintRanks(u User) (string, error) {
+	categories, err := b.store.GetCategories()
 	if err != nil {
 		return "", err
 	}
 
 	ranks := fmt.Sprintf("Ranks for %s:\n", u.Name)
 	for _, category := range categories {
-		r, err := category.GetUserRank(u.ID)
+		r, err := b.store.GetUserRank(category, u.ID)
 		if err != nil {
 			return "", err
 		}
-		ranks += fmt.Sprintf("%s: %v\n", category.FullName, r)
+		ranks += fmt.Sprintf("%s: %v", category.FullName, r)
+		if stat, err := b.store.GetStat(category, u); err == nil {
+			if progress := category.TierProgress(stat.Value); progress != "" {
+				ranks += " " + progress
+			}
+		}
+		ranks += "\n"
 	}
 
 	return ranks, nil
 }
 
-func SendReminders(s *discordgo.Session, channelID string) error {
-	users, err := GetActiveUsers()
+func (b *Bot) SendReminders(ctx context.Context, channelID string) error {
+	users, err := b.store.GetActiveUsers()
 	if err != nil {
 		return err
 	}
 
-	categories, err := GetCategories() 
+	categories, err := b.store.GetCategories()
 	if err != nil {
 		return err
 	}
@@ -134,13 +210,16 @@ func SendReminders(s *discordgo.Session, channelID string) error {
 	cutoff := now.Add(-24 * time.Hour * 7)
 
 	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		outdated := []string{}
 		checks := map[string]bool{}
-	
+
 		for _, cat := range categories {
 			checks[cat.FullName] = true
 		}
-		stats, err := user.GetStats()
+		stats, err := b.store.GetStats(user)
 		if err != nil {
 			return err
 		}
@@ -156,7 +235,7 @@ func SendReminders(s *discordgo.Session, channelID string) error {
 			missing = append(missing, c)
 		}
 		if len(outdated) > 0 || len(missing) > 0 {
-			dUser, err := s.User(user.DiscordID)
+			dUser, err := b.session.User(user.DiscordID)
 			var message string
 			if err != nil {
 				message = user.Name + ":\n"
@@ -170,7 +249,7 @@ func SendReminders(s *discordgo.Session, channelID string) error {
 				message += "You are missing the following stats: " + strings.Join(missing, ", ") + "\n"
 			}
 			message += "Use `!stats help` for more information."
-			_, _ = s.ChannelMessageSend(channelID, message)
+			_, _ = b.session.ChannelMessageSend(channelID, message)
 		}
 	}
 	return nil
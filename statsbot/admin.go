@@ -0,0 +1,539 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/haynesherway/statsbot"
+)
+
+var ERR_USAGE = errors.New("Invalid arguments.")
+
+// adminActor is who the audit log credits with changes made by the admin commands
+const adminActor = "admin tool"
+
+// adminCommand is a subcommand that works on the database without connecting to discord
+type adminCommand struct {
+	Usage string
+	Do    func(store *statsbot.Store, args []string) error
+}
+
+var adminCommands = map[string]map[string]adminCommand{
+	"user": {
+		"add":        {"user add {discordID} {name}", userAdd},
+		"promote":    {"user promote {user}", userPromote},
+		"deactivate": {"user deactivate {user}", userDeactivate},
+	},
+	"category": {
+		"list":  {"category list", categoryList},
+		"add":   {"category add [-min n] [-max n] [-order n] [-type t] [-decimals n] [-sort highest|lowest] [-monotonic] [-max-daily n] [-ceiling category] [-review] [-tiers b,s,g,p] {name} {full name}", categoryAdd},
+		"edit":  {"category edit [-name s] [-min n] [-max n] [-order n] [-image url] [-type t] [-decimals n] [-sort highest|lowest] [-monotonic bool] [-max-daily n] [-ceiling category|none] [-review bool] [-tiers b,s,g,p] {category}", categoryEdit},
+		"image": {"category image {category} {file}", categoryImage},
+	},
+	"stat": {
+		"set":    {"stat set {category} {user} {value}", statSet},
+		"delete": {"stat delete {category} {user}", statDelete},
+	},
+	"export": {
+		"": {"export [file]", export},
+	},
+	"import": {
+		"": {"import [-csv] [-dry-run] [file]", importBackup},
+	},
+	"migrate": {
+		"": {"migrate [-seed]", migrate},
+	},
+}
+
+// adminUsage lists the usage of every command in the group
+func adminUsage(group map[string]adminCommand) []string {
+	names := []string{}
+	for name := range group {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	usage := []string{}
+	for _, name := range names {
+		usage = append(usage, group[name].Usage)
+	}
+	return usage
+}
+
+// admin runs the admin subcommand named by args, it returns the process exit code
+func admin(args []string) int {
+	group, ok := adminCommands[args[0]]
+	if !ok {
+		flag.Usage()
+		return 2
+	}
+
+	args = args[1:]
+	cmd, ok := group[""]
+	if !ok {
+		if len(args) > 0 {
+			cmd, ok = group[args[0]]
+			args = args[1:]
+		}
+		if !ok {
+			for _, usage := range adminUsage(group) {
+				fmt.Fprintln(os.Stderr, "Usage: statsbot "+usage)
+			}
+			return 2
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	store, err := statsbot.OpenStore(cfg.DSN())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to start stats database: %+v\n", err.Error())
+		return 1
+	}
+	defer store.Close()
+
+	err = cmd.Do(store, args)
+	if err == ERR_USAGE {
+		fmt.Fprintln(os.Stderr, "Usage: statsbot "+cmd.Usage)
+		return 2
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+func userAdd(store *statsbot.Store, args []string) error {
+	if len(args) != 2 {
+		return ERR_USAGE
+	}
+
+	u := statsbot.User{DiscordID: args[0], Name: args[1]}
+	err := store.InsertUser(&u)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added user %s (%s)\n", u.Name, u.DiscordID)
+	return nil
+}
+
+func userPromote(store *statsbot.Store, args []string) error {
+	if len(args) != 1 {
+		return ERR_USAGE
+	}
+
+	u, err := store.GetUser(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = store.SetAdmin(&u, true)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now an admin\n", u.Name)
+	return nil
+}
+
+func userDeactivate(store *statsbot.Store, args []string) error {
+	if len(args) != 1 {
+		return ERR_USAGE
+	}
+
+	u, err := store.GetUser(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = store.SetActive(&u, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now inactive\n", u.Name)
+	return nil
+}
+
+func categoryList(store *statsbot.Store, args []string) error {
+	if len(args) != 0 {
+		return ERR_USAGE
+	}
+
+	categories, err := store.GetCategories()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFULL NAME\tMIN\tMAX\tORDER\tTYPE\tDECIMALS\tBEST\tRULES\tTIERS")
+	for _, c := range categories {
+		best := "highest"
+		if c.LowerIsBetter {
+			best = "lowest"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%d\t%s\t%s\t%d,%d,%d,%d\n", c.Name, c.FullName, c.Min, c.Max, c.Order, c.ValueType(), c.Decimals, best, c.Rules(), c.Bronze, c.Silver, c.Gold, c.Platinum)
+	}
+	return w.Flush()
+}
+
+func categoryAdd(store *statsbot.Store, args []string) error {
+	fs := flag.NewFlagSet("category add", flag.ContinueOnError)
+	min := fs.Int("min", 0, "Smallest allowed value")
+	max := fs.Int("max", 100000, "Largest allowed value")
+	order := fs.Int("order", 0, "Display order")
+	valueType := fs.String("type", statsbot.TypeInteger, "Value type: "+strings.Join(statsbot.ValueTypes, ", "))
+	decimals := fs.Int("decimals", 0, "Decimal places kept for decimal, distance and percentage values")
+	sortBy := fs.String("sort", "highest", "Which value ranks first: highest or lowest")
+	monotonic := fs.Bool("monotonic", false, "Values can never get worse")
+	maxDaily := fs.Int("max-daily", 0, "Most a value can improve per day, 0 for no limit")
+	ceiling := fs.String("ceiling", "", "Category whose value this one can't exceed")
+	review := fs.Bool("review", false, "Hold values that break a rule for moderators instead of rejecting them")
+	tiers := fs.String("tiers", "", "Bronze, silver, gold and platinum thresholds separated by commas")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return ERR_USAGE
+	}
+	lower, err := parseSort(*sortBy)
+	if err != nil {
+		return err
+	}
+	if err := statsbot.CheckValueType(*valueType, *decimals); err != nil {
+		return err
+	}
+
+	c := statsbot.Category{
+		Name:     fs.Arg(0),
+		FullName: fs.Arg(1),
+		Min:      *min,
+		Max:      *max,
+		Order:    *order,
+		Type:     *valueType,
+		Decimals: *decimals,
+
+		LowerIsBetter: lower,
+		Monotonic:     *monotonic,
+		MaxDailyGain:  *maxDaily,
+		Ceiling:       *ceiling,
+		Review:        *review,
+	}
+	if err := checkCeiling(store, c); err != nil {
+		return err
+	}
+	if *tiers != "" {
+		if err := c.SetTiers(*tiers); err != nil {
+			return err
+		}
+	}
+	err = store.AddCategory(&c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added category %s (%s)\n", c.FullName, c.Name)
+	return nil
+}
+
+func categoryEdit(store *statsbot.Store, args []string) error {
+	fs := flag.NewFlagSet("category edit", flag.ContinueOnError)
+	fullName := fs.String("name", "", "Display name")
+	min := fs.String("min", "", "Smallest allowed value")
+	max := fs.String("max", "", "Largest allowed value")
+	order := fs.String("order", "", "Display order")
+	image := fs.String("image", "", "Icon URL")
+	valueType := fs.String("type", "", "Value type: "+strings.Join(statsbot.ValueTypes, ", "))
+	decimals := fs.Int("decimals", -1, "Decimal places kept, existing values are rescaled")
+	sortBy := fs.String("sort", "", "Which value ranks first: highest or lowest")
+	monotonic := fs.String("monotonic", "", "Values can never get worse: true or false")
+	maxDaily := fs.String("max-daily", "", "Most a value can improve per day, 0 for no limit")
+	ceiling := fs.String("ceiling", "", "Category whose value this one can't exceed, none to remove it")
+	review := fs.String("review", "", "Hold values that break a rule for moderators: true or false")
+	tiers := fs.String("tiers", "", "Bronze, silver, gold and platinum thresholds separated by commas")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return ERR_USAGE
+	}
+
+	c, err := store.GetCategory(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *fullName != "" {
+		c.FullName = *fullName
+	}
+	if *image != "" {
+		c.Image = *image
+	}
+	if *sortBy != "" {
+		c.LowerIsBetter, err = parseSort(*sortBy)
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range []struct {
+		value string
+		field *int
+	}{{*min, &c.Min}, {*max, &c.Max}, {*order, &c.Order}, {*maxDaily, &c.MaxDailyGain}} {
+		if f.value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f.value)
+		if err != nil {
+			return statsbot.ERR_INVALID_VALUE
+		}
+		*f.field = n
+	}
+	for _, f := range []struct {
+		value string
+		field *bool
+	}{{*monotonic, &c.Monotonic}, {*review, &c.Review}} {
+		if f.value == "" {
+			continue
+		}
+		v, err := strconv.ParseBool(f.value)
+		if err != nil {
+			return statsbot.ERR_INVALID_VALUE
+		}
+		*f.field = v
+	}
+	if *tiers != "" {
+		if err := c.SetTiers(*tiers); err != nil {
+			return err
+		}
+	}
+	if *ceiling == "none" {
+		c.Ceiling = ""
+	} else if *ceiling != "" {
+		c.Ceiling = *ceiling
+		if err := checkCeiling(store, c); err != nil {
+			return err
+		}
+	}
+
+	if *valueType == "" {
+		*valueType = c.ValueType()
+	}
+	if *decimals < 0 {
+		*decimals = c.Decimals
+	}
+	err = store.EditCategory(&c, *valueType, *decimals)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated category %s\n", c.Name)
+	return nil
+}
+
+// parseSort reads a -sort flag, reporting whether the lowest value ranks first
+func parseSort(s string) (lower bool, err error) {
+	switch strings.ToLower(s) {
+	case "highest":
+		return false, nil
+	case "lowest":
+		return true, nil
+	}
+	return false, fmt.Errorf("Sort must be highest or lowest, not %q.", s)
+}
+
+// checkCeiling makes sure the category's ceiling is another existing category
+func checkCeiling(store *statsbot.Store, c statsbot.Category) error {
+	if c.Ceiling == "" {
+		return nil
+	}
+	if c.Ceiling == c.Name {
+		return fmt.Errorf("%s can't be its own ceiling.", c.Name)
+	}
+	_, err := store.GetCategory(c.Ceiling)
+	if err != nil {
+		return fmt.Errorf("Unknown ceiling category %s.", c.Ceiling)
+	}
+	return nil
+}
+
+func categoryImage(store *statsbot.Store, args []string) error {
+	if len(args) != 2 {
+		return ERR_USAGE
+	}
+
+	c, err := store.GetCategory(args[0])
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	err = store.SetCategoryImage(c, data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated image for %s\n", c.Name)
+	return nil
+}
+
+func statSet(store *statsbot.Store, args []string) error {
+	if len(args) != 3 {
+		return ERR_USAGE
+	}
+
+	c, err := store.GetCategory(args[0])
+	if err != nil {
+		return err
+	}
+	u, err := store.GetUser(args[1])
+	if err != nil {
+		return err
+	}
+	v, err := c.ParseValue(args[2])
+	if err != nil {
+		return err
+	}
+
+	e := statsbot.AuditEntry{Action: "set", Actor: adminActor, TargetID: u.DiscordID, Target: u.Name, Category: c.Name, NewValue: c.FormatValue(v)}
+	if old, err := store.GetStat(c, u); err == nil {
+		e.OldValue = c.FormatValue(old.Value)
+	}
+
+	err = store.AddStat(c, u, v)
+	if err != nil {
+		return err
+	}
+	err = store.AddAudit(&e)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Set %s for %s to %s\n", c.FullName, u.Name, c.FormatValue(v))
+	return nil
+}
+
+func statDelete(store *statsbot.Store, args []string) error {
+	if len(args) != 2 {
+		return ERR_USAGE
+	}
+
+	c, err := store.GetCategory(args[0])
+	if err != nil {
+		return err
+	}
+	u, err := store.GetUser(args[1])
+	if err != nil {
+		return err
+	}
+
+	old, err := store.GetStat(c, u)
+	if err != nil {
+		return err
+	}
+
+	err = store.DeleteStat(c, u)
+	if err != nil {
+		return err
+	}
+	err = store.AddAudit(&statsbot.AuditEntry{Action: "remove", Actor: adminActor, TargetID: u.DiscordID, Target: u.Name, Category: c.Name, OldValue: c.FormatValue(old.Value)})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted %s for %s\n", c.FullName, u.Name)
+	return nil
+}
+
+func export(store *statsbot.Store, args []string) error {
+	if len(args) > 1 {
+		return ERR_USAGE
+	}
+
+	var w io.Writer = os.Stdout
+	if len(args) == 1 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return store.Export(w)
+}
+
+func importBackup(store *statsbot.Store, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	csv := fs.Bool("csv", false, "Import stats from a CSV file instead of an export")
+	dryRun := fs.Bool("dry-run", false, "Check a CSV file without importing it")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 1 {
+		return ERR_USAGE
+	}
+
+	var r io.Reader = os.Stdin
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+
+		if strings.HasSuffix(strings.ToLower(fs.Arg(0)), ".csv") {
+			*csv = true
+		}
+	}
+
+	if *csv {
+		report, err := store.ImportCSV(r, *dryRun)
+		if err != nil {
+			return err
+		}
+		fmt.Print(report.String())
+		return nil
+	} else if *dryRun {
+		return ERR_USAGE
+	}
+
+	err := store.Import(r)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Import complete")
+	return nil
+}
+
+func migrate(store *statsbot.Store, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	seed := fs.Bool("seed", false, "Add the default categories and admins")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return ERR_USAGE
+	}
+
+	err := store.Migrate()
+	if err != nil {
+		return err
+	}
+
+	if *seed {
+		err = store.Seed()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Migration complete")
+	return nil
+}
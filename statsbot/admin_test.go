@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/haynesherway/statsbot"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func newTestStore(t *testing.T) *statsbot.Store {
+	t.Helper()
+
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "stats.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := statsbot.NewStore(db)
+	t.Cleanup(func() { s.Close() })
+
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Seed(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStatCommandsAreAudited(t *testing.T) {
+	store := newTestStore(t)
+	if err := userAdd(store, []string{"1", "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{{"jogger", "alice", "5"}, {"jogger", "alice", "7.5"}} {
+		if err := statSet(store, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := statDelete(store, []string{"jogger", "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.GetAudit(statsbot.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []statsbot.AuditEntry{
+		{Action: "remove", OldValue: "7.5 km"},
+		{Action: "set", OldValue: "5.0 km", NewValue: "7.5 km"},
+		{Action: "set", NewValue: "5.0 km"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got audit entries %+v", entries)
+	}
+	for i, e := range entries {
+		if e.Action != want[i].Action || e.OldValue != want[i].OldValue || e.NewValue != want[i].NewValue || e.Actor != adminActor || e.Target != "alice" {
+			t.Errorf("entry %d is %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestCategoryEditIsAllOrNothing(t *testing.T) {
+	store := newTestStore(t)
+	if err := userAdd(store, []string{"1", "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := statSet(store, []string{"collector", "alice", "5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := categoryEdit(store, []string{"-name", "Hoarder", "-type", statsbot.TypeDate, "collector"}); err == nil {
+		t.Fatal("converting a category with values to dates should fail")
+	}
+	if c, _ := store.GetCategory("collector"); c.FullName != "Collector" || c.ValueType() != statsbot.TypeInteger {
+		t.Errorf("a failed edit saved %+v", c)
+	}
+}
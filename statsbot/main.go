@@ -1,21 +1,166 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path"
+	"runtime"
+	"syscall"
+
+	"github.com/bwmarrin/discordgo"
 	"github.com/haynesherway/statsbot"
 )
 
+var (
+	test  bool
+	debug bool
+)
+
+func init() {
+	flag.BoolVar(&test, "t", false, "Run for testing")
+	flag.BoolVar(&debug, "debug", false, "Log at debug level, including message contents")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [-t] [-debug] [command]\n\n", os.Args[0])
+		fmt.Fprintln(out, "Without a command the bot connects to discord. Commands:")
+		fmt.Fprintln(out, "  console [-as discordID]")
+		for _, group := range []string{"user", "category", "stat", "export", "import", "migrate"} {
+			for _, usage := range adminUsage(adminCommands[group]) {
+				fmt.Fprintln(out, "  "+usage)
+			}
+		}
+		fmt.Fprintln(out)
+		flag.PrintDefaults()
+	}
+}
+
 func main() {
-	err := statsbot.ReadConfig()
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "":
+		os.Exit(run())
+	case "console":
+		os.Exit(console(flag.Args()[1:]))
+	case "user", "category", "stat", "export", "import", "migrate":
+		os.Exit(admin(flag.Args()))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// loadConfig reads config.json from the repository root and sets up logging from it
+func loadConfig() (*statsbot.Config, error) {
+	_, filename, _, ok := runtime.Caller(0)
+	if !ok {
+		return nil, fmt.Errorf("Unable to get config file location")
+	}
+
+	cfg, err := statsbot.ReadConfig(path.Join(path.Dir(filename), "../config.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	if test {
+		cfg.BotPrefix = "?"
+	}
+	if debug {
+		cfg.LogLevel = "debug"
+	}
+
+	logger, err := cfg.NewLogger(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+
+	return cfg, nil
+}
+
+// run starts the bot and blocks until it is stopped, it returns the process exit code
+func run() int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
 
+	store, err := statsbot.OpenStore(cfg.DSN())
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		fmt.Fprintf(os.Stderr, "Unable to start stats database: %+v\n", err.Error())
+		return 1
 	}
+	defer store.Close()
 
-	statsbot.Start()
+	session, err := discordgo.New("Bot " + cfg.Token)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
 
-	<-make(chan struct{})
+	bot, err := statsbot.New(cfg, store, session)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer bot.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = bot.Run(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// console runs commands read from stdin against the database without connecting to discord
+func console(args []string) int {
+	fs := flag.NewFlagSet("console", flag.ExitOnError)
+	as := fs.String("as", "0", "Discord ID of the user to run commands as")
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	store, err := statsbot.OpenStore(cfg.DSN())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to start stats database: %+v\n", err.Error())
+		return 1
+	}
+	defer store.Close()
+
+	bot, err := statsbot.NewWithSession(cfg, store, statsbot.NewConsoleSession(os.Stdout))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer bot.Close()
+
+	author := &discordgo.User{ID: *as, Username: "console"}
+	if u, err := store.GetUser(*as); err == nil {
+		author.Username = u.Name
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = bot.Console(ctx, os.Stdin, author)
+	if err != nil && err != context.Canceled {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
 
-	return
+	return 0
 }
@@ -0,0 +1,216 @@
+package statsbot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Teams are the in-game teams a user can be on
+var Teams = []string{"mystic", "valor", "instinct"}
+
+var teamEmoji = map[string]string{"mystic": "💙", "valor": "❤️", "instinct": "💛"}
+
+var ERR_INVALID_TEAM = errors.New("Teams are mystic, valor and instinct.")
+
+// TeamStat is a team's aggregate for one category
+type TeamStat struct {
+	Team    string
+	Users   int
+	Sum     int
+	Average float64
+	Median  float64
+}
+
+// teamName is how the team is shown, with its emoji
+func teamName(team string) string {
+	if team == "" {
+		return "no team"
+	}
+	return teamEmoji[team] + " " + strings.ToUpper(team[:1]) + team[1:]
+}
+
+// parseTeam returns the team named s in any case
+func parseTeam(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, t := range Teams {
+		if s == t {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// SetTeam puts the user on the team, custom is set when they picked it themselves so their roles no longer change it
+func (s *Store) SetTeam(u *User, team string, custom bool) error {
+	u.Team, u.CustomTeam = team, custom
+	return s.db.Model(u).Updates(map[string]interface{}{"team": team, "custom_team": custom}).Error
+}
+
+// SyncTeam updates the user's team to the one from their roles unless they picked their own
+func (s *Store) SyncTeam(u *User, team string) error {
+	if u.CustomTeam || team == "" || team == u.Team {
+		return nil
+	}
+	u.Team = team
+	return s.db.Model(u).Update("team", team).Error
+}
+
+// filterTeam keeps the stats of users on the team, every stat is kept when team is empty
+func filterTeam(stats []Stat, team string) []Stat {
+	if team == "" {
+		return stats
+	}
+	filtered := []Stat{}
+	for _, stat := range stats {
+		if stat.User.Team == team {
+			filtered = append(filtered, stat)
+		}
+	}
+	return filtered
+}
+
+// filterTeamRows keeps the rows of users on the team, ranks stay the overall ones
+func filterTeamRows(rows []ExportRow, team string) []ExportRow {
+	filtered := []ExportRow{}
+	for _, r := range rows {
+		if r.Team == team {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// TeamStats returns the aggregate of each team that has values in the category, best average first
+func (s *Store) TeamStats(c Category) ([]TeamStat, error) {
+	stats, err := s.GetAll(c)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := []TeamStat{}
+	for _, team := range Teams {
+		values := []int{}
+		for _, stat := range filterTeam(stats, team) {
+			values = append(values, stat.Value)
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		ts := TeamStat{Team: team, Users: len(values)}
+		for _, v := range values {
+			ts.Sum += v
+		}
+		ts.Average = float64(ts.Sum) / float64(len(values))
+
+		sort.Ints(values)
+		mid := len(values) / 2
+		if len(values)%2 == 0 {
+			ts.Median = float64(values[mid-1]+values[mid]) / 2
+		} else {
+			ts.Median = float64(values[mid])
+		}
+		teams = append(teams, ts)
+	}
+
+	sort.SliceStable(teams, func(i, j int) bool {
+		if c.LowerIsBetter {
+			return teams[i].Average < teams[j].Average
+		}
+		return teams[i].Average > teams[j].Average
+	})
+	return teams, nil
+}
+
+// PrintTeams writes the team board for the category
+func (b *Bot) PrintTeams(c Category) (string, error) {
+	teams, err := b.store.TeamStats(c)
+	if err != nil {
+		return "", err
+	}
+	if len(teams) == 0 {
+		return fmt.Sprintf("No team has a %s value yet.", c.FullName), nil
+	}
+
+	message := fmt.Sprintf("%s by team:\n", c.FullName)
+	for i, ts := range teams {
+		message += fmt.Sprintf("%d. %s (%d): total %s, average %s, median %s\n", i+1, teamName(ts.Team), ts.Users,
+			c.FormatValue(ts.Sum), c.FormatValue(int(math.Round(ts.Average))), c.FormatValue(int(math.Round(ts.Median))))
+	}
+	return message, nil
+}
+
+// memberTeam returns the team named by one of the member's roles, it is empty
+// if they have no team role or more than one
+func memberTeam(s Session, guildID string, m *discordgo.Member) string {
+	if m == nil || len(m.Roles) == 0 || guildID == "" {
+		return ""
+	}
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		return ""
+	}
+
+	names := map[string]string{}
+	for _, r := range guild.Roles {
+		names[r.ID] = strings.ToLower(r.Name)
+	}
+
+	found := ""
+	for _, id := range m.Roles {
+		for _, t := range Teams {
+			if !strings.Contains(names[id], t) || found == t {
+				continue
+			}
+			if found != "" {
+				return ""
+			}
+			found = t
+		}
+	}
+	return found
+}
+
+// Team runs !stats team, which shows the author's team or sets it from args
+func (b *Bot) Team(m *discordgo.MessageCreate, args []string) (string, error) {
+	user, err := b.store.GetUserByDiscordID(m.Author.ID)
+	if err != nil {
+		return "", ERR_NOT_REGISTERED
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		if user.Team == "" {
+			err = b.store.SyncTeam(&user, memberTeam(b.session, m.GuildID, m.Member))
+			if err != nil {
+				return "", err
+			}
+		}
+		if user.Team == "" {
+			return "You haven't picked a team, use `!stats team mystic`, `!stats team valor` or `!stats team instinct`.", nil
+		}
+		return fmt.Sprintf("You are on team %s.", teamName(user.Team)), nil
+	}
+
+	if strings.EqualFold(args[0], "none") {
+		err = b.store.SetTeam(&user, "", false)
+		if err != nil {
+			return "", err
+		}
+		return "You are no longer on a team, your server roles will pick one if they have a team.", nil
+	}
+
+	team, ok := parseTeam(args[0])
+	if !ok {
+		return "", ERR_INVALID_TEAM
+	}
+	err = b.store.SetTeam(&user, team, true)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Welcome to team %s!", teamName(team)), nil
+}
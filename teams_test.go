@@ -0,0 +1,47 @@
+package statsbot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestTeams(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	f.Guilds["g"] = &discordgo.Guild{ID: "g", Roles: []*discordgo.Role{{ID: "r1", Name: "Team Valor"}, {ID: "r2", Name: "Mystic"}}}
+
+	if got := send(b, f, "1", "!stats team purple"); len(got) != 1 || got[0] != ERR_INVALID_TEAM.Error() {
+		t.Errorf("invalid team replied %q", got)
+	}
+	send(b, f, "1", "!stats team mystic")
+
+	// bob's team comes from his role, alice picked hers so her roles don't change it
+	b.HandleMemberUpdate(&discordgo.Member{GuildID: "g", User: &discordgo.User{ID: "2", Username: "bob"}, Roles: []string{"r1"}})
+	b.HandleMemberUpdate(&discordgo.Member{GuildID: "g", User: &discordgo.User{ID: "1", Username: "alice"}, Roles: []string{"r1"}})
+	if bob, _ := b.store.GetUserByDiscordID("2"); bob.Team != "valor" {
+		t.Errorf("bob's team is %q, want valor", bob.Team)
+	}
+	if alice, _ := b.store.GetUserByDiscordID("1"); alice.Team != "mystic" {
+		t.Errorf("alice's team is %q, want mystic", alice.Team)
+	}
+
+	send(b, f, "1", "!stats add collector 100")
+	send(b, f, "2", "!stats add collector 300")
+
+	got := send(b, f, "1", "!stats teams collector")
+	if len(got) != 1 || strings.Index(got[0], "Valor") > strings.Index(got[0], "Mystic") {
+		t.Errorf("teams replied %q", got)
+	}
+
+	// the team filter keeps overall ranks on discord and in the API alike
+	got = send(b, f, "1", "!stats print collector mystic")
+	if len(got) != 1 || !strings.Contains(got[0], "2. alice") || strings.Contains(got[0], "bob") {
+		t.Errorf("mystic board replied %q", got)
+	}
+	var page struct{ Items []ExportRow }
+	if code := apiGet(b, "/categories/collector/leaderboard?team=mystic", &page); code != http.StatusOK || len(page.Items) != 1 || page.Items[0].Rank != 2 {
+		t.Errorf("mystic API board got %d %+v", code, page)
+	}
+}
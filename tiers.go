@@ -0,0 +1,138 @@
+package statsbot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Tier is a medal level, Threshold is the stored value needed to reach it
+type Tier struct {
+	Name      string `json:"name"`
+	Emoji     string `json:"emoji"`
+	Threshold int    `json:"threshold"`
+}
+
+// tierNames and tierEmoji are the medal levels from lowest to highest
+var (
+	tierNames = []string{"Bronze", "Silver", "Gold", "Platinum"}
+	tierEmoji = []string{"🥉", "🥈", "🥇", "💎"}
+)
+
+// String is the tier's emoji and name, it is empty for the zero Tier
+func (t Tier) String() string {
+	if t.Name == "" {
+		return ""
+	}
+	return t.Emoji + " " + t.Name
+}
+
+// Tiers returns the category's medal levels from lowest to highest, levels without a threshold are left out
+func (c Category) Tiers() []Tier {
+	tiers := []Tier{}
+	for i, t := range []int{c.Bronze, c.Silver, c.Gold, c.Platinum} {
+		if t == 0 {
+			continue
+		}
+		tiers = append(tiers, Tier{Name: tierNames[i], Emoji: tierEmoji[i], Threshold: int(int64(t) * c.scale())})
+	}
+	return tiers
+}
+
+// Tier returns the highest tier v has reached, or the zero Tier if it has reached none
+func (c Category) Tier(v int) Tier {
+	reached := Tier{}
+	for _, t := range c.Tiers() {
+		if !c.Better(t.Threshold, v) {
+			reached = t
+		}
+	}
+	return reached
+}
+
+// NextTier returns the lowest tier v hasn't reached yet, ok is false once every tier is reached
+func (c Category) NextTier(v int) (next Tier, ok bool) {
+	for _, t := range c.Tiers() {
+		if c.Better(t.Threshold, v) {
+			return t, true
+		}
+	}
+	return next, false
+}
+
+// TierProgress describes v's tier and how far it is from the next one
+func (c Category) TierProgress(v int) string {
+	progress := c.Tier(v).String()
+	if next, ok := c.NextTier(v); ok {
+		if progress != "" {
+			progress += ", "
+		}
+		progress += fmt.Sprintf("%s to %s", c.FormatValue(c.Gain(v, next.Threshold)), next)
+	}
+	return progress
+}
+
+// SetTiers sets the category's thresholds from "bronze,silver,gold,platinum" in displayed units, use 0 to skip a level
+func (c *Category) SetTiers(s string) error {
+	fields := strings.Split(s, ",")
+	if len(fields) != len(tierNames) {
+		return fmt.Errorf("Give the %s thresholds separated by commas.", strings.ToLower(strings.Join(tierNames, ", ")))
+	}
+
+	thresholds := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := Category{}.ParseValue(f)
+		if err != nil || v < 0 {
+			return ERR_INVALID_VALUE
+		}
+		thresholds[i] = v
+	}
+
+	previous := 0
+	for _, t := range thresholds {
+		if t == 0 {
+			continue
+		}
+		if previous != 0 && !c.Better(t, previous) {
+			return errors.New("Each tier has to be harder to reach than the one before it.")
+		}
+		previous = t
+	}
+
+	c.Bronze, c.Silver, c.Gold, c.Platinum = thresholds[0], thresholds[1], thresholds[2], thresholds[3]
+	return nil
+}
+
+// PrintTiers lists the category's tiers with how many users reached each, and
+// where the user stands if they have a value
+func (b *Bot) PrintTiers(c Category, u *User) (string, error) {
+	tiers := c.Tiers()
+	if len(tiers) == 0 {
+		return fmt.Sprintf("%s has no tiers.", c.FullName), nil
+	}
+
+	stats, err := b.store.GetAll(c)
+	if err != nil {
+		return "", err
+	}
+	counts := map[string]int{}
+	for _, stat := range stats {
+		counts[c.Tier(stat.Value).Name]++
+	}
+
+	message := fmt.Sprintf("%s tiers:\n", c.FullName)
+	for _, t := range tiers {
+		message += fmt.Sprintf("%s %s (%d)\n", t, c.FormatValue(t.Threshold), counts[t.Name])
+	}
+
+	if u != nil {
+		if stat, err := b.store.GetStat(c, *u); err == nil {
+			message += fmt.Sprintf("You have %s", c.FormatValue(stat.Value))
+			if progress := c.TierProgress(stat.Value); progress != "" {
+				message += ": " + progress
+			}
+			message += "."
+		}
+	}
+	return message, nil
+}
@@ -0,0 +1,52 @@
+package statsbot
+
+import "testing"
+
+func TestTierCrossing(t *testing.T) {
+	higher := Category{FullName: "Collector", Bronze: 30, Silver: 500, Gold: 2000}
+	lower := Category{FullName: "Speedrun", LowerIsBetter: true, Bronze: 600, Silver: 300, Gold: 120}
+
+	for _, tc := range []struct {
+		c        Category
+		v        int
+		tier     string
+		progress string
+	}{
+		{c: higher, v: 29, tier: "", progress: "1 to 🥉 Bronze"},
+		{c: higher, v: 30, tier: "Bronze", progress: "🥉 Bronze, 470 to 🥈 Silver"},
+		{c: higher, v: 1999, tier: "Silver", progress: "🥈 Silver, 1 to 🥇 Gold"},
+		{c: higher, v: 5000, tier: "Gold", progress: "🥇 Gold"},
+		{c: lower, v: 601, tier: "", progress: "1 to 🥉 Bronze"},
+		{c: lower, v: 600, tier: "Bronze", progress: "🥉 Bronze, 300 to 🥈 Silver"},
+		{c: lower, v: 121, tier: "Silver", progress: "🥈 Silver, 1 to 🥇 Gold"},
+		{c: lower, v: 90, tier: "Gold", progress: "🥇 Gold"},
+	} {
+		if got := tc.c.Tier(tc.v).Name; got != tc.tier {
+			t.Errorf("%s %d: got tier %q, want %q", tc.c.FullName, tc.v, got, tc.tier)
+		}
+		if got := tc.c.TierProgress(tc.v); got != tc.progress {
+			t.Errorf("%s %d: got progress %q, want %q", tc.c.FullName, tc.v, got, tc.progress)
+		}
+	}
+}
+
+func TestSetTiers(t *testing.T) {
+	for _, tc := range []struct {
+		lower bool
+		tiers string
+		ok    bool
+	}{
+		{tiers: "10,100,1000,10000", ok: true},
+		{tiers: "10,0,1000,0", ok: true},
+		{tiers: "100,10,1000,10000"},
+		{tiers: "10,100,1000"},
+		{tiers: "10,-1,1000,10000"},
+		{lower: true, tiers: "600,300,120,60", ok: true},
+		{lower: true, tiers: "60,120,300,600"},
+	} {
+		c := Category{LowerIsBetter: tc.lower}
+		if err := c.SetTiers(tc.tiers); (err == nil) != tc.ok {
+			t.Errorf("SetTiers(%q) with lower %v: got %v", tc.tiers, tc.lower, err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package statsbot
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Category value types, values are stored as integers scaled by 10^Decimals
+const (
+	TypeInteger    = "integer"
+	TypeDecimal    = "decimal"
+	TypeDistance   = "distance"
+	TypePercentage = "percentage"
+	TypeDate       = "date"
+)
+
+// ValueTypes are the value types a category can have
+var ValueTypes = []string{TypeInteger, TypeDecimal, TypeDistance, TypePercentage, TypeDate}
+
+// maxDecimals is the most decimal places a category can keep
+const maxDecimals = 4
+
+// dateLayout is how date values are written, they are stored as YYYYMMDD
+const dateLayout = "2006-01-02"
+
+// ValueType returns the category's value type, categories without one hold integers
+func (c Category) ValueType() string {
+	if c.Type == "" {
+		return TypeInteger
+	}
+	return c.Type
+}
+
+// CheckValueType reports whether the type and decimals can be used for a category
+func CheckValueType(valueType string, decimals int) error {
+	for _, t := range ValueTypes {
+		if t != valueType {
+			continue
+		}
+		if decimals < 0 || decimals > maxDecimals {
+			return fmt.Errorf("Decimals must be between 0 and %d.", maxDecimals)
+		}
+		if (valueType == TypeInteger || valueType == TypeDate) && decimals != 0 {
+			return fmt.Errorf("%s values can't have decimals.", valueType)
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown value type %s, use one of: %s", valueType, strings.Join(ValueTypes, ", "))
+}
+
+// scale is the factor between a displayed value and the stored one
+func (c Category) scale() int64 {
+	n := int64(1)
+	for i := 0; i < c.Decimals; i++ {
+		n *= 10
+	}
+	return n
+}
+
+// ParseValue reads a value typed by a user for the category. Thousands
+// separators are ignored and integer and decimal values can use k and M
+// suffixes, so 1,234 and 12.5k both work.
+func (c Category) ParseValue(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if c.ValueType() == TypeDate {
+		t, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return 0, ERR_INVALID_VALUE
+		}
+		return t.Year()*10000 + int(t.Month())*100 + t.Day(), nil
+	}
+
+	multiplier := int64(1)
+	switch c.ValueType() {
+	case TypeDistance:
+		s = strings.TrimSpace(strings.TrimSuffix(s, "km"))
+	case TypePercentage:
+		s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+	default:
+		if strings.HasSuffix(s, "k") {
+			s, multiplier = strings.TrimSuffix(s, "k"), 1000
+		} else if strings.HasSuffix(s, "m") {
+			s, multiplier = strings.TrimSuffix(s, "m"), 1000000
+		}
+	}
+	s = strings.NewReplacer(",", "", "_", "", " ", "").Replace(s)
+
+	// big.Rat would also take fractions and exponents
+	if s == "" || strings.ContainsAny(s, "/eE") {
+		return 0, ERR_INVALID_VALUE
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, ERR_INVALID_VALUE
+	}
+	r.Mul(r, new(big.Rat).SetInt64(multiplier*c.scale()))
+
+	// round half away from zero to the category's decimals
+	num, denom := r.Num(), r.Denom()
+	q, m := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(denom) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	if !q.IsInt64() || q.Int64() != int64(int(q.Int64())) {
+		return 0, ERR_INVALID_VALUE
+	}
+	return int(q.Int64()), nil
+}
+
+// FormatValue writes a stored value with thousands separators and the category's unit
+func (c Category) FormatValue(v int) string {
+	if c.ValueType() == TypeDate {
+		return fmt.Sprintf("%04d-%02d-%02d", v/10000, v/100%100, v%100)
+	}
+
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+
+	scale := int(c.scale())
+	s := sign + groupThousands(strconv.Itoa(v/scale))
+	if c.Decimals > 0 {
+		s += fmt.Sprintf(".%0*d", c.Decimals, v%scale)
+	}
+
+	switch c.ValueType() {
+	case TypeDistance:
+		s += " km"
+	case TypePercentage:
+		s += "%"
+	}
+	return s
+}
+
+// Number is the value in displayed units without FormatValue's formatting, dates stay YYYYMMDD
+func (c Category) Number(v int) float64 {
+	if c.ValueType() == TypeDate {
+		return float64(v)
+	}
+	return float64(v) / float64(c.scale())
+}
+
+// groupThousands adds commas between each group of three digits
+func groupThousands(digits string) string {
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
+
+// Validate reports whether the stored value v is within the category's Min and
+// Max, which are in displayed units. Dates only have to be real dates.
+func (c *Category) Validate(v int) bool {
+	if c.ValueType() == TypeDate {
+		_, err := time.Parse(dateLayout, c.FormatValue(v))
+		return err == nil
+	}
+
+	scale := c.scale()
+	return int64(v) >= int64(c.Min)*scale && int64(v) <= int64(c.Max)*scale
+}
+
+// SetValueType changes the category's value type and decimals, rescaling the stored values to match
+func (s *Store) SetValueType(c *Category, valueType string, decimals int) error {
+	err := CheckValueType(valueType, decimals)
+	if err != nil {
+		return err
+	}
+
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	err = s.withDB(tx).setValueType(*c, valueType, decimals)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit().Error
+	if err == nil {
+		c.Type, c.Decimals = valueType, decimals
+	}
+	return err
+}
+
+// EditCategory saves the changes made to the category and changes its value
+// type and decimals like SetValueType, in one transaction so a failure leaves both undone
+func (s *Store) EditCategory(c *Category, valueType string, decimals int) error {
+	err := CheckValueType(valueType, decimals)
+	if err != nil {
+		return err
+	}
+
+	tx := s.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	txs := s.withDB(tx)
+
+	err = txs.UpdateCategory(c)
+	if err == nil {
+		err = txs.setValueType(*c, valueType, decimals)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit().Error
+	if err == nil {
+		c.Type, c.Decimals = valueType, decimals
+	}
+	return err
+}
+
+func (s *Store) setValueType(c Category, valueType string, decimals int) error {
+	if (c.ValueType() == TypeDate) != (valueType == TypeDate) {
+		var count int
+		res := s.db.Model(&Stat{}).Where("category_id = ?", c.ID).Count(&count)
+		if res.Error != nil {
+			return res.Error
+		}
+		if count > 0 {
+			return fmt.Errorf("%s already has values, they can't be converted to or from dates.", c.FullName)
+		}
+	}
+
+	if decimals != c.Decimals {
+		var expr string
+		if decimals > c.Decimals {
+			expr = fmt.Sprintf("value * %d", Category{Decimals: decimals - c.Decimals}.scale())
+		} else {
+			expr = fmt.Sprintf("ROUND(value / %d.0)", Category{Decimals: c.Decimals - decimals}.scale())
+		}
+
+		events := s.db.Model(&Event{}).Select("id").Where("category_id = ?", c.ID).SubQuery()
+		for _, q := range []*gorm.DB{
+			s.db.Unscoped().Model(&Stat{}).Where("category_id = ?", c.ID),
+			s.db.Model(&StatHistory{}).Where("category_id = ?", c.ID),
+			s.db.Model(&PendingStat{}).Where("category_id = ?", c.ID),
+			s.db.Model(&EventSnapshot{}).Where("event_id IN ?", events),
+		} {
+			res := q.UpdateColumn("value", gorm.Expr(expr))
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+	}
+
+	return s.db.Model(&c).Updates(map[string]interface{}{"type": valueType, "decimals": decimals}).Error
+}
@@ -0,0 +1,78 @@
+package statsbot
+
+import "testing"
+
+func TestParseValue(t *testing.T) {
+	integer := Category{}
+	distance := Category{Type: TypeDistance, Decimals: 1}
+	percentage := Category{Type: TypePercentage, Decimals: 2}
+	date := Category{Type: TypeDate}
+
+	for _, tc := range []struct {
+		c    Category
+		in   string
+		want int
+		ok   bool
+	}{
+		{integer, "1234", 1234, true},
+		{integer, "1,234", 1234, true},
+		{integer, "12.5k", 12500, true},
+		{integer, "2M", 2000000, true},
+		{integer, "2.5", 3, true},
+		{integer, "-2.5", -3, true},
+		{integer, "1/2", 0, false},
+		{integer, "1e3", 0, false},
+		{integer, "", 0, false},
+		{integer, "lots", 0, false},
+		{distance, "123.45 km", 1235, true},
+		{distance, "1,000km", 10000, true},
+		{percentage, "99.5%", 9950, true},
+		{date, "2016-07-06", 20160706, true},
+		{date, "2016-13-01", 0, false},
+	} {
+		got, err := tc.c.ParseValue(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("%s ParseValue(%q) = %d, %v, want %d", tc.c.ValueType(), tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	for _, tc := range []struct {
+		c    Category
+		v    int
+		want string
+	}{
+		{Category{}, 1234567, "1,234,567"},
+		{Category{}, -1234, "-1,234"},
+		{Category{Type: TypeDistance, Decimals: 1}, 12345, "1,234.5 km"},
+		{Category{Type: TypeDecimal, Decimals: 2}, 5, "0.05"},
+		{Category{Type: TypePercentage, Decimals: 1}, 995, "99.5%"},
+		{Category{Type: TypeDate}, 20160706, "2016-07-06"},
+	} {
+		if got := tc.c.FormatValue(tc.v); got != tc.want {
+			t.Errorf("%s FormatValue(%d) = %q, want %q", tc.c.ValueType(), tc.v, got, tc.want)
+		}
+		if back, err := tc.c.ParseValue(tc.want); err != nil || back != tc.v {
+			t.Errorf("%s ParseValue(%q) = %d, %v, want %d", tc.c.ValueType(), tc.want, back, err, tc.v)
+		}
+	}
+}
+
+func TestSetValueTypeRescales(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add jogger 12.3")
+
+	c, _ := b.store.GetCategory("jogger")
+	if err := b.store.SetValueType(&c, TypeDistance, 3); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := b.store.GetUser("alice")
+	stat, _ := b.store.GetStat(c, u)
+	if got := c.FormatValue(stat.Value); got != "12.300 km" {
+		t.Errorf("got %s after rescaling", got)
+	}
+	if err := b.store.SetValueType(&c, TypeDate, 0); err == nil {
+		t.Error("a category with values can't become a date")
+	}
+}
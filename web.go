@@ -0,0 +1,190 @@
+package statsbot
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+//go:embed web/*.html
+var webTemplates embed.FS
+
+var dashboardTemplates = template.Must(template.ParseFS(webTemplates, "web/*.html"))
+
+type dashboardCategory struct {
+	Category
+	Icon string
+}
+
+type dashboardPage struct {
+	Title      string
+	Error      string
+	Query      string
+	Categories []dashboardCategory
+	Category   dashboardCategory
+	Rows       []ExportRow
+	User       User
+	Ranks      []dashboardRank
+	Users      []User
+
+	// Teams can filter the leaderboard, Team is the one it is filtered to
+	Teams []string
+	Team  string
+}
+
+type dashboardRank struct {
+	CategoryRank
+	Icon string
+}
+
+func (b *Bot) registerDashboard(mux *http.ServeMux) {
+	mux.HandleFunc("GET /img/{name}", b.serveCategoryImage)
+	mux.HandleFunc("GET /{$}", b.dashboardIndex)
+	mux.HandleFunc("GET /leaderboards/{name}", b.dashboardLeaderboard)
+	mux.HandleFunc("GET /profiles/{name}", b.dashboardProfile)
+	mux.HandleFunc("GET /search", b.dashboardSearch)
+}
+
+// medalIcon returns the URL of the category's image, relative to the bot's HTTP server if it serves it
+func (b *Bot) medalIcon(c Category) string {
+	if c.Image != "" {
+		return c.Image
+	}
+	if b.store.HasMedalImage(c) {
+		return "/img/" + c.Name
+	}
+	return ""
+}
+
+func (b *Bot) dashboardCategories() ([]dashboardCategory, error) {
+	categories, err := b.store.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []dashboardCategory{}
+	for _, c := range categories {
+		list = append(list, dashboardCategory{Category: c, Icon: b.medalIcon(c)})
+	}
+	return list, nil
+}
+
+func (b *Bot) dashboardIndex(w http.ResponseWriter, r *http.Request) {
+	categories, err := b.dashboardCategories()
+	if err != nil {
+		renderDashboardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	renderDashboard(w, "index.html", dashboardPage{Title: "Leaderboards", Categories: categories})
+}
+
+func (b *Bot) dashboardLeaderboard(w http.ResponseWriter, r *http.Request) {
+	category, err := b.store.GetCategory(r.PathValue("name"))
+	if err == gorm.ErrRecordNotFound {
+		renderDashboardError(w, http.StatusNotFound, errors.New("No such category."))
+		return
+	} else if err != nil {
+		renderDashboardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	rows, err := b.store.ExportRows(category.Name)
+	if err != nil {
+		renderDashboardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	team := ""
+	if q := r.URL.Query().Get("team"); q != "" {
+		var ok bool
+		if team, ok = parseTeam(q); !ok {
+			renderDashboardError(w, http.StatusBadRequest, ERR_INVALID_TEAM)
+			return
+		}
+		rows = filterTeamRows(rows, team)
+	}
+
+	renderDashboard(w, "leaderboard.html", dashboardPage{
+		Title:    category.FullName,
+		Category: dashboardCategory{Category: category, Icon: b.medalIcon(category)},
+		Rows:     rows,
+		Teams:    Teams,
+		Team:     team,
+	})
+}
+
+func (b *Bot) dashboardProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := b.store.GetUser(r.PathValue("name"))
+	if err == gorm.ErrRecordNotFound {
+		renderDashboardError(w, http.StatusNotFound, errors.New("No such user."))
+		return
+	} else if err != nil {
+		renderDashboardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ranks, err := b.store.GetRanks(user)
+	if err != nil {
+		renderDashboardError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	page := dashboardPage{Title: user.Name, User: user}
+	for _, rank := range ranks {
+		page.Ranks = append(page.Ranks, dashboardRank{CategoryRank: rank, Icon: b.medalIcon(rank.Category)})
+	}
+	renderDashboard(w, "profile.html", page)
+}
+
+func (b *Bot) dashboardSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	page := dashboardPage{Title: "Search", Query: q}
+
+	if q != "" {
+		users, err := b.store.SearchUsers(q)
+		if err != nil {
+			renderDashboardError(w, http.StatusInternalServerError, err)
+			return
+		}
+		page.Users = users
+
+		categories, err := b.dashboardCategories()
+		if err != nil {
+			renderDashboardError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, c := range categories {
+			if strings.Contains(strings.ToLower(c.Name+" "+c.FullName), strings.ToLower(q)) {
+				page.Categories = append(page.Categories, c)
+			}
+		}
+	}
+
+	renderDashboard(w, "search.html", page)
+}
+
+func renderDashboard(w http.ResponseWriter, name string, page dashboardPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplates.ExecuteTemplate(w, name, page)
+	if err != nil {
+		slog.Error("Unable to render dashboard", "template", name, "error", err)
+	}
+}
+
+// renderDashboardError shows err on an error page, server errors are logged and shown as their status instead
+func renderDashboardError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		slog.Error("Dashboard request failed", "error", err)
+		err = errors.New("Something went wrong, try again later.")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	dashboardTemplates.ExecuteTemplate(w, "error.html", dashboardPage{Title: http.StatusText(status), Error: err.Error()})
+}
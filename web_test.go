@@ -0,0 +1,57 @@
+package statsbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func dashboardGet(b *Bot, path string) (int, string) {
+	rec := httptest.NewRecorder()
+	b.HTTPHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestDashboard(t *testing.T) {
+	b, f := newTestBot(t, nil)
+	send(b, f, "1", "!stats add jogger 12.5")
+	send(b, f, "2", "!stats add jogger 3")
+
+	for _, tc := range []struct {
+		path   string
+		status int
+		want   []string
+	}{
+		{"/", http.StatusOK, []string{"Jogger", "/leaderboards/jogger"}},
+		{"/leaderboards/jogger", http.StatusOK, []string{"alice", "12.5 km", "bob", "3.0 km"}},
+		{"/leaderboards/jogger?team=nosuchteam", http.StatusBadRequest, []string{ERR_INVALID_TEAM.Error()}},
+		{"/leaderboards/nosuchcategory", http.StatusNotFound, []string{"No such category."}},
+		{"/profiles/alice", http.StatusOK, []string{"alice", "12.5 km", "1/2"}},
+		{"/profiles/nobody", http.StatusNotFound, []string{"No such user."}},
+		{"/search?q=ali", http.StatusOK, []string{"/profiles/1"}},
+	} {
+		status, body := dashboardGet(b, tc.path)
+		if status != tc.status {
+			t.Errorf("%s got status %d, want %d", tc.path, status, tc.status)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s is missing %q", tc.path, want)
+			}
+		}
+	}
+}
+
+func TestDashboardHidesServerErrors(t *testing.T) {
+	b, _ := newTestBot(t, nil)
+	b.store.Close()
+
+	status, body := dashboardGet(b, "/")
+	if status != http.StatusInternalServerError {
+		t.Fatalf("got status %d", status)
+	}
+	if strings.Contains(body, "sql") || !strings.Contains(body, "Something went wrong") {
+		t.Errorf("error page shows %q", body)
+	}
+}